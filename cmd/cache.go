@@ -0,0 +1,45 @@
+// cmd/cache.go
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mubbie/stacksmith/internal/render"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "🗃️ Manage the cache of computed stack state",
+	Long: `Stacksmith caches ahead/behind counts, merged status and guessed parents in
+.git/stacksmith/cache, keyed by the commits they were computed from. Entries are
+ignored as soon as a branch moves, so the cache never needs clearing to stay correct.
+
+Pass --no-cache to any command to work the stack out from scratch.`,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "🧹 Remove the cached stack state",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
+
+		if err := git.ClearCache(); err != nil {
+			printer.Error(fmt.Sprintf("Error clearing cache: %s", err))
+			return
+		}
+
+		if reportDryRun(printer, git) {
+			return
+		}
+
+		printer.Success("Cache cleared")
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,84 @@
+// cmd/doctor.go
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mubbie/stacksmith/internal/render"
+	"github.com/spf13/cobra"
+)
+
+var doctorFix bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "🩺 Check the recorded stack for problems and repair them",
+	Long: `Check the recorded branch relationships for cycles, branches recorded as their own
+parent, parents that no longer exist, branches that no longer build on their parent,
+stale entries and a trunk that doesn't match origin.
+
+Each problem is offered for repair, or repaired without asking with --fix.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		defer finishOperation()
+
+		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
+
+		issues, err := git.Diagnose()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error checking stack: %s", err))
+			return
+		}
+
+		if len(issues) == 0 {
+			printer.Success("No problems found, your stack is healthy")
+			return
+		}
+
+		printer.Warning(fmt.Sprintf("Found %d problem(s):", len(issues)))
+		for _, issue := range issues {
+			printer.BulletPoint(issue.Message)
+		}
+
+		if !recordOperation(printer, git, "doctor") {
+			return
+		}
+
+		fixed := 0
+		for _, issue := range issues {
+			if issue.Fix == "" {
+				printer.Warning(fmt.Sprintf("No automatic repair for: %s", issue.Message))
+				continue
+			}
+
+			if !doctorFix && !confirm(fmt.Sprintf("%s. %s?", issue.Message, issue.Fix)) {
+				continue
+			}
+
+			if err := git.Repair(issue); err != nil {
+				printer.Error(fmt.Sprintf("Error repairing %s: %s", issue.Branch, err))
+				continue
+			}
+			fixed++
+
+			if !git.DryRun {
+				printer.Success(issue.Fix)
+			}
+		}
+
+		if reportDryRun(printer, git) {
+			return
+		}
+
+		if remaining := len(issues) - fixed; remaining > 0 {
+			printer.Info(fmt.Sprintf("%d problem(s) left unrepaired", remaining))
+		} else {
+			printer.Info("Run 'stacksmith sync' to restack any branch that moved onto a new parent")
+		}
+	},
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "Repair every problem without asking")
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -14,12 +14,21 @@ import (
 var fixPrCmd = &cobra.Command{
 	Use:   "fix-pr [branch] [target]",
 	Short: "🔧 Rebase one branch onto a new base",
-	Long:  `Rebase a branch onto a new target and remind to retarget the PR.`,
+	Long: `Rebase a branch onto a new target and remind to retarget the PR.
+
+If the rebase stops on a conflict, resolve it and run 'stacksmith fix-pr --continue',
+or run 'stacksmith fix-pr --abort' to put the branch back where it started.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		defer finishOperation()
 		var branch, target string
 		var success bool
 
 		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
+
+		if handleResumeFlags(printer, git) {
+			return
+		}
 
 		if len(args) < 2 {
 			// Not enough arguments, launch the interactive prompt
@@ -33,46 +42,24 @@ var fixPrCmd = &cobra.Command{
 			target = args[1]
 		}
 
-		git := core.NewGitExecutor("")
-
 		printer.FixPrStart(branch, target)
 
-		err := git.CheckoutBranch(branch)
-		if err != nil {
-			printer.Error(fmt.Sprintf("Error checking out %s: %s", branch, err))
-			return
-		}
-
-		err = git.FetchRemote()
-		if err != nil {
-			printer.Error(fmt.Sprintf("Error fetching remote: %s", err))
-			return
-		}
-
 		// For fix-pr, we rebase onto the target (which might be a remote branch)
 		rebaseTarget := target
 		if !strings.HasPrefix(target, "origin/") {
 			rebaseTarget = "origin/" + target
 		}
 
-		err = git.RebaseBranch(rebaseTarget)
-		if err != nil {
-			printer.Error(fmt.Sprintf("Error rebasing onto %s: %s", rebaseTarget, err))
-			return
-		}
-
-		err = git.PushBranch()
-		if err != nil {
-			printer.Error(fmt.Sprintf("Error pushing %s: %s", branch, err))
+		if !noOperationInProgress(printer, git) || !recordOperation(printer, git, fmt.Sprintf("fix-pr %s %s", branch, target)) {
 			return
 		}
 
-		printer.Success(fmt.Sprintf("Successfully rebased %s onto %s", branch, target))
-		printer.RetargetReminder(branch, target)
+		startRestack(printer, git, "fix-pr", []core.RestackStep{{Branch: branch, Parent: rebaseTarget}})
 	},
 	Args: cobra.MaximumNArgs(2),
 }
 
 func init() {
+	addResumeFlags(fixPrCmd)
 	rootCmd.AddCommand(fixPrCmd)
 }
@@ -0,0 +1,208 @@
+// cmd/fold.go
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mubbie/stacksmith/internal/core"
+	"github.com/mubbie/stacksmith/internal/render"
+	"github.com/spf13/cobra"
+)
+
+var (
+	foldSquash       bool
+	foldDeleteRemote bool
+)
+
+var foldCmd = &cobra.Command{
+	Use:   "fold [branch]",
+	Short: "🪗 Collapse a branch into its parent",
+	Long: `Add a branch's commits to its parent, optionally squashed into one commit, delete the
+branch and move its children onto the parent, then restack and push them.
+
+The branch has to be built on its parent's latest commit, run 'stacksmith sync' first
+if it isn't. If restacking the children stops on a conflict, resolve it and run
+'stacksmith fold --continue', or run 'stacksmith fold --abort' to bring the folded branch
+back and put every branch where it started. Once the fold has finished, 'stacksmith undo'
+brings it back.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		defer finishOperation()
+
+		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
+
+		if handleResumeFlags(printer, git) {
+			return
+		}
+
+		currentBranch, err := git.GetCurrentBranch()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error getting current branch: %s", err))
+			return
+		}
+		branch := currentBranch
+		if len(args) > 0 {
+			branch = args[0]
+		}
+
+		stack, err := git.BuildBranchStack()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error analyzing branch structure: %s", err))
+			return
+		}
+
+		node := stack.AllNodes[branch]
+		if node == nil {
+			printer.HandleGitError(&core.BranchNotFoundError{BranchName: branch})
+			return
+		}
+		parent := stack.ParentOf(branch)
+		if parent == "" {
+			printer.ErrorWithSolution(
+				fmt.Sprintf("%s has no recorded parent to fold into", branch),
+				"Run 'stacksmith doctor' to record missing parents",
+			)
+			return
+		}
+		if parent == stack.MainBranch {
+			printer.ErrorWithSolution(
+				fmt.Sprintf("%s is built on trunk, stacksmith won't fold it into %s", branch, parent),
+				"Merge its PR instead, then run 'stacksmith tidy'",
+			)
+			return
+		}
+
+		for _, name := range []string{branch, parent} {
+			if path := stack.AllNodes[name].Worktree; path != "" {
+				printer.ErrorWithSolution(
+					fmt.Sprintf("%s is checked out in another worktree", name),
+					fmt.Sprintf("Check out a different branch in %s first", path),
+				)
+				return
+			}
+		}
+
+		if !git.IsAncestor(parent, branch) {
+			printer.ErrorWithSolution(
+				fmt.Sprintf("%s is not built on the latest %s", branch, parent),
+				fmt.Sprintf("Run 'stacksmith sync %s' first", parent),
+			)
+			return
+		}
+
+		// The children move onto the parent, everything above them keeps its parent. The
+		// children's fork points are resolved against the folded branch while it still exists.
+		var steps []core.RestackStep
+		for _, child := range stack.ChildrenOf(branch) {
+			upstream, err := git.ForkPoint(child, branch)
+			if err != nil {
+				printer.Error(fmt.Sprintf("Error finding where %s forked from %s: %s", child, branch, err))
+				return
+			}
+			steps = append(steps, core.RestackStep{Branch: child, Parent: parent, Upstream: upstream})
+
+			descendants, err := stack.DescendantSteps(child)
+			if err != nil {
+				printer.HandleGitError(err)
+				return
+			}
+			steps = append(steps, descendants...)
+		}
+
+		// Push the parent together with the restacked children, then remove the folded branch
+		journal := core.NewOperationJournal("fold", "", steps)
+		journal.PushAlso = []string{parent}
+		if foldDeleteRemote {
+			journal.DeleteRemote = []string{branch}
+		}
+		if currentBranch == branch {
+			journal.OriginalBranch = parent // The branch we started on is about to go
+		}
+
+		// Folding moves the parent and deletes the branch, an abort puts both back
+		for _, name := range []string{branch, parent} {
+			sha, err := git.GetBranchSHA(name)
+			if err != nil {
+				printer.HandleGitError(err)
+				return
+			}
+			journal.OriginalSHAs[name] = sha
+		}
+
+		// Everything that can stop the fold happens before the first change
+		if !prepareJournal(printer, git, journal) {
+			return
+		}
+
+		command := "fold " + branch
+		if foldSquash {
+			command += " --squash"
+		}
+		if foldDeleteRemote {
+			command += " --delete-remote"
+		}
+		if !recordOperation(printer, git, command) {
+			return
+		}
+
+		stash, ok := stashLocalChanges(printer, git, "fold")
+		if !ok {
+			return
+		}
+		journal.Stash = stash
+
+		if !foldIntoParent(printer, git, branch, parent, currentBranch) {
+			restoreStash(printer, git, stash)
+			return
+		}
+
+		saveAndRunJournal(printer, git, journal)
+	},
+	Args: cobra.MaximumNArgs(1),
+}
+
+// foldIntoParent moves parent up to include branch, deletes branch and moves its children
+// onto parent, returning false if the fold stopped
+func foldIntoParent(printer *render.Printer, git *core.GitExecutor, branch, parent, currentBranch string) bool {
+	if err := git.FoldBranch(branch, parent, foldSquash); err != nil {
+		printer.HandleGitError(err)
+		return false
+	}
+
+	// Can't delete the branch we're standing on
+	if currentBranch == branch {
+		if err := git.CheckoutBranch(parent); err != nil {
+			printer.HandleGitError(err)
+			return false
+		}
+	}
+
+	children, err := git.ReparentChildren(branch, parent)
+	if err != nil {
+		printer.Error(fmt.Sprintf("Error reparenting children of %s: %s", branch, err))
+		return false
+	}
+	if err := git.DeleteBranch(branch); err != nil {
+		printer.HandleGitError(err)
+		return false
+	}
+	if err := git.ForgetBranch(branch); err != nil {
+		printer.Warning(fmt.Sprintf("Failed to remove %s from stack config: %s", branch, err))
+	}
+
+	if !git.DryRun {
+		printer.Success(fmt.Sprintf("Folded %s into %s", branch, parent))
+		if len(children) > 0 {
+			printer.Info(fmt.Sprintf("Moved %s onto %s", strings.Join(children, ", "), parent))
+		}
+	}
+	return true
+}
+
+func init() {
+	foldCmd.Flags().BoolVar(&foldSquash, "squash", false, "Squash the branch's commits into one commit on the parent")
+	foldCmd.Flags().BoolVar(&foldDeleteRemote, "delete-remote", false, "Also delete the folded branch on the remote")
+	addResumeFlags(foldCmd)
+	rootCmd.AddCommand(foldCmd)
+}
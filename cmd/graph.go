@@ -4,7 +4,6 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/mubbie/stacksmith/internal/core"
 	"github.com/mubbie/stacksmith/internal/render"
 	"github.com/spf13/cobra"
 )
@@ -15,7 +14,7 @@ var graphCmd = &cobra.Command{
 	Long:  `Visualize the branch stack structure showing parent-child relationships.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		printer := render.NewPrinter("stacksmith")
-		git := core.NewGitExecutor("")
+		git := newGitExecutor()
 
 		printer.GraphHeader()
 		printer.Divider()
@@ -45,9 +44,19 @@ var graphCmd = &cobra.Command{
 		printer.Divider()
 		printer.Info("Legend: " + 
 		             "👈 HEAD branch • " + 
-		             "✔ merged into parent • " + 
+		             "✔ merged into parent (or landed in trunk) • " + 
 		             "🔁 (+n/-m) ahead/behind counts • " +
-		             "⚠ orphaned branch")
+		             "⚠ orphaned branch • " +
+		             "🌲 checked out in another worktree")
+		for _, guess := range stack.Suggested {
+			printer.Info(fmt.Sprintf("💡 %s may build on %s (%s confidence: %s)", guess.Branch, guess.Parent, guess.Confidence, guess.Reason()))
+		}
+		if len(stack.Suggested) > 0 {
+			printer.Info("Run 'stacksmith doctor' to record suggested parents")
+		}
+		if merged := stack.MergedIntoTrunk(); len(merged) > 0 {
+			printer.Info(fmt.Sprintf("%d branch(es) already landed in %s. Run 'stacksmith tidy' to reparent their children and delete them", len(merged), stack.MainBranch))
+		}
 		printer.Info("Branch relationships stored in .stacksmith/stack.yml")
 		printer.Info("Tip: For a more detailed view, try 'stacksmith tui' (coming soon)")
 	},
@@ -0,0 +1,124 @@
+// cmd/insert.go
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mubbie/stacksmith/internal/core"
+	"github.com/mubbie/stacksmith/internal/render"
+	"github.com/spf13/cobra"
+)
+
+var insertAfter string
+
+var insertCmd = &cobra.Command{
+	Use:   "insert <new-branch> --after <branch>",
+	Short: "🪚 Slip a new branch into the middle of a stack",
+	Long: `Create a new branch on top of --after, move the branches stacked on --after onto
+the new branch, and restack them.
+
+If a rebase stops on a conflict, resolve it and run 'stacksmith insert --continue',
+or run 'stacksmith insert --abort' to put every branch back where it started.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		defer finishOperation()
+
+		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
+
+		if handleResumeFlags(printer, git) {
+			return
+		}
+
+		if len(args) != 1 || insertAfter == "" {
+			printer.ErrorWithSolution("Name the new branch and where it goes", "stacksmith insert <new-branch> --after <branch>")
+			return
+		}
+		newBranch := args[0]
+
+		stack, err := git.BuildBranchStack()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error analyzing branch structure: %s", err))
+			return
+		}
+
+		if stack.AllNodes[insertAfter] == nil {
+			printer.HandleGitError(&core.BranchNotFoundError{BranchName: insertAfter})
+			return
+		}
+		if stack.AllNodes[newBranch] != nil {
+			printer.Error(fmt.Sprintf("A branch named %s already exists", newBranch))
+			return
+		}
+
+		children := stack.ChildrenOf(insertAfter)
+		command := fmt.Sprintf("insert %s --after %s", newBranch, insertAfter)
+		if len(children) == 0 {
+			if !noOperationInProgress(printer, git) || !recordOperation(printer, git, command) {
+				return
+			}
+			if err := git.InsertBranch(newBranch, insertAfter, nil); err != nil {
+				printer.HandleGitError(err)
+				return
+			}
+			if reportDryRun(printer, git) {
+				return
+			}
+			printer.ForgeSuccess(newBranch, insertAfter)
+			return
+		}
+
+		// The children move onto the new branch, everything above them stays where it is.
+		// Their fork points are resolved against insertAfter, since the new branch doesn't exist yet.
+		var steps []core.RestackStep
+		for _, child := range children {
+			upstream, err := git.ForkPoint(child, insertAfter)
+			if err != nil {
+				printer.Error(fmt.Sprintf("Error finding where %s forked from %s: %s", child, insertAfter, err))
+				return
+			}
+			steps = append(steps, core.RestackStep{Branch: child, Parent: newBranch, Upstream: upstream})
+
+			descendants, err := stack.DescendantSteps(child)
+			if err != nil {
+				printer.HandleGitError(err)
+				return
+			}
+			steps = append(steps, descendants...)
+		}
+
+		journal := core.NewOperationJournal("insert", "", steps)
+		journal.Created = []string{newBranch}
+
+		// Everything that can stop the insert happens before the first change
+		if !prepareJournal(printer, git, journal) || !recordOperation(printer, git, command) {
+			return
+		}
+
+		stash, ok := stashLocalChanges(printer, git, "insert")
+		if !ok {
+			return
+		}
+		journal.Stash = stash
+
+		if err := git.InsertBranch(newBranch, insertAfter, children); err != nil {
+			printer.HandleGitError(err)
+			restoreStash(printer, git, stash)
+			return
+		}
+
+		if !git.DryRun {
+			printer.ForgeSuccess(newBranch, insertAfter)
+			printer.Info(fmt.Sprintf("Moved %s onto %s", strings.Join(children, ", "), newBranch))
+		}
+
+		saveAndRunJournal(printer, git, journal)
+	},
+	Args: cobra.MaximumNArgs(1),
+}
+
+func init() {
+	insertCmd.Flags().StringVar(&insertAfter, "after", "", "Branch to insert the new branch on top of")
+	addResumeFlags(insertCmd)
+	rootCmd.AddCommand(insertCmd)
+}
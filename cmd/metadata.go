@@ -0,0 +1,115 @@
+// cmd/metadata.go
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mubbie/stacksmith/internal/core"
+	"github.com/mubbie/stacksmith/internal/render"
+	"github.com/spf13/cobra"
+)
+
+var metadataPreferTheirs bool
+
+var metadataCmd = &cobra.Command{
+	Use:   "metadata",
+	Short: "🤝 Share stack relationships with your team",
+	Long: `Share the recorded branch relationships through the refs/stacksmith/stack ref on origin,
+so teammates who check out your stack see the same parents instead of guessed ones.`,
+}
+
+var metadataPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "⬆️ Publish your stack relationships to origin",
+	Run: func(cmd *cobra.Command, args []string) {
+		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
+
+		err := git.PushMetadata()
+		if errors.Is(err, core.ErrMetadataDiverged) {
+			printer.ErrorWithSolution(
+				"Your teammates have published stack changes you don't have yet",
+				"Run 'stacksmith metadata fetch' to merge them, then push again",
+			)
+			return
+		}
+		if err != nil {
+			printer.HandleGitError(err)
+			return
+		}
+
+		if reportDryRun(printer, git) {
+			return
+		}
+		printer.Success("Published stack relationships to origin")
+	},
+}
+
+var metadataFetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "⬇️ Fetch and merge your team's stack relationships",
+	Long: `Fetch the stack relationships published on origin and merge them into yours.
+
+A branch whose parent was changed both locally and on origin keeps your parent,
+unless --theirs is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		defer finishOperation()
+
+		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
+
+		if !recordOperation(printer, git, "metadata fetch") {
+			return
+		}
+
+		result, err := git.FetchMetadata(metadataPreferTheirs)
+		if err != nil {
+			printer.HandleGitError(err)
+			return
+		}
+
+		if reportDryRun(printer, git) {
+			return
+		}
+
+		if !result.Shared {
+			printer.Info("No stack relationships have been published to origin yet")
+			return
+		}
+
+		if len(result.Updated) > 0 {
+			printer.Success("Updated parents from origin: " + strings.Join(result.Updated, ", "))
+		} else {
+			printer.Success("Your stack relationships are up to date with origin")
+		}
+
+		for _, conflict := range result.Conflicts {
+			printer.Warning(fmt.Sprintf("%s: parent is %s here but %s on origin",
+				conflict.Branch, describeParent(conflict.Ours), describeParent(conflict.Theirs)))
+		}
+		if len(result.Conflicts) > 0 {
+			if metadataPreferTheirs {
+				printer.Info("Kept origin's parents; run 'stacksmith metadata push' to share the result")
+			} else {
+				printer.Info("Kept your parents; run 'stacksmith metadata push' to share them")
+			}
+		}
+	},
+}
+
+// describeParent names a parent for a conflict message
+func describeParent(parent string) string {
+	if parent == "" {
+		return "(removed)"
+	}
+	return parent
+}
+
+func init() {
+	metadataFetchCmd.Flags().BoolVar(&metadataPreferTheirs, "theirs", false, "Take origin's parent when both sides changed a branch")
+	metadataCmd.AddCommand(metadataPushCmd)
+	metadataCmd.AddCommand(metadataFetchCmd)
+	rootCmd.AddCommand(metadataCmd)
+}
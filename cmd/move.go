@@ -0,0 +1,119 @@
+// cmd/move.go
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mubbie/stacksmith/internal/core"
+	"github.com/mubbie/stacksmith/internal/render"
+	"github.com/spf13/cobra"
+)
+
+var moveOnto string
+
+var moveCmd = &cobra.Command{
+	Use:   "move <branch> --onto <new-parent>",
+	Short: "🚚 Move a branch and everything stacked on it onto a new parent",
+	Long: `Record a new parent for a branch, then rebase the branch and all of its descendants
+in order, so the whole subtree moves together.
+
+If a rebase stops on a conflict, resolve it and run 'stacksmith move --continue',
+or run 'stacksmith move --abort' to put every branch back where it started.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		defer finishOperation()
+
+		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
+
+		if handleResumeFlags(printer, git) {
+			return
+		}
+
+		if len(args) != 1 || moveOnto == "" {
+			printer.ErrorWithSolution("Name the branch to move and its new parent", "stacksmith move <branch> --onto <new-parent>")
+			return
+		}
+		branch := args[0]
+
+		stack, err := git.BuildBranchStack()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error analyzing branch structure: %s", err))
+			return
+		}
+
+		for _, name := range []string{branch, moveOnto} {
+			if stack.AllNodes[name] == nil {
+				printer.HandleGitError(&core.BranchNotFoundError{BranchName: name})
+				return
+			}
+		}
+		if branch == stack.MainBranch {
+			printer.Error(fmt.Sprintf("%s is trunk and can't be moved", branch))
+			return
+		}
+		if stack.ParentOf(branch) == moveOnto {
+			printer.ErrorWithSolution(
+				fmt.Sprintf("%s is already stacked on %s", branch, moveOnto),
+				fmt.Sprintf("Run 'stacksmith sync %s' to restack it", moveOnto),
+			)
+			return
+		}
+
+		// Moving a branch onto itself or anything above it would make a cycle
+		for ancestor := moveOnto; ancestor != ""; ancestor = stack.ParentOf(ancestor) {
+			if ancestor == branch {
+				printer.Error(fmt.Sprintf("Can't move %s onto %s, which is stacked on it", branch, moveOnto))
+				return
+			}
+			if ancestor == stack.MainBranch {
+				break
+			}
+		}
+
+		// Descendants keep their parents, so their steps come from the stack as it was. The
+		// branch's own fork point is resolved against the parent it is leaving.
+		oldParent := stack.ParentOf(branch)
+		descendants, err := stack.DescendantSteps(branch)
+		if err != nil {
+			printer.HandleGitError(err)
+			return
+		}
+		moved := core.RestackStep{Branch: branch, Parent: moveOnto}
+		if oldParent != "" {
+			if moved.Upstream, err = git.ForkPoint(branch, oldParent); err != nil {
+				printer.Error(fmt.Sprintf("Error finding where %s forked from %s: %s", branch, oldParent, err))
+				return
+			}
+		}
+		journal := core.NewOperationJournal("move", "", append([]core.RestackStep{moved}, descendants...))
+
+		// Everything that can stop the move happens before the first change
+		if !prepareJournal(printer, git, journal) {
+			return
+		}
+		if !recordOperation(printer, git, fmt.Sprintf("move %s --onto %s", branch, moveOnto)) {
+			return
+		}
+
+		stash, ok := stashLocalChanges(printer, git, "move")
+		if !ok {
+			return
+		}
+		journal.Stash = stash
+
+		if err := git.MoveBranch(branch, oldParent, moveOnto); err != nil {
+			printer.HandleGitError(err)
+			restoreStash(printer, git, stash)
+			return
+		}
+
+		saveAndRunJournal(printer, git, journal)
+	},
+	Args: cobra.MaximumNArgs(1),
+}
+
+func init() {
+	moveCmd.Flags().StringVar(&moveOnto, "onto", "", "Branch to move the branch onto")
+	addResumeFlags(moveCmd)
+	rootCmd.AddCommand(moveCmd)
+}
@@ -0,0 +1,194 @@
+// cmd/navigate.go
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/mubbie/stacksmith/internal/core"
+	"github.com/mubbie/stacksmith/internal/render"
+	"github.com/mubbie/stacksmith/internal/ui/simplemenu"
+	"github.com/spf13/cobra"
+)
+
+var upCmd = &cobra.Command{
+	Use:   "up [n]",
+	Short: "⬆️ Check out the child of the current branch",
+	Long: `Check out the branch stacked on the current one, or n branches up the stack.
+When a branch has more than one child you pick which one to follow.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		navigate(args, func(printer *render.Printer, stack *core.BranchStack, current string, n int) (string, bool) {
+			branch := current
+			for i := 0; i < n; i++ {
+				child, ok := pickChild(stack, branch)
+				if !ok {
+					return "", false
+				}
+				if child == "" {
+					if i == 0 {
+						printer.Info(fmt.Sprintf("%s is already at the top of its stack", branch))
+					}
+					break
+				}
+				branch = child
+			}
+			return branch, true
+		})
+	},
+}
+
+var downCmd = &cobra.Command{
+	Use:   "down [n]",
+	Short: "⬇️ Check out the parent of the current branch",
+	Long:  `Check out the branch the current one is stacked on, or n branches down the stack.`,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		navigate(args, func(printer *render.Printer, stack *core.BranchStack, current string, n int) (string, bool) {
+			branch := current
+			for i := 0; i < n; i++ {
+				parent := stack.ParentOf(branch)
+				if parent == "" {
+					if i == 0 {
+						printer.Info(fmt.Sprintf("%s is already at the bottom of its stack", branch))
+					}
+					break
+				}
+				branch = parent
+			}
+			return branch, true
+		})
+	},
+}
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "⏫ Check out the last branch of the current stack",
+	Long: `Follow children up from the current branch to the top of the stack.
+When a branch has more than one child you pick which one to follow.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		navigate(args, func(printer *render.Printer, stack *core.BranchStack, current string, n int) (string, bool) {
+			branch := current
+			visited := map[string]bool{branch: true}
+			for {
+				child, ok := pickChild(stack, branch)
+				if !ok {
+					return "", false
+				}
+				if child == "" || visited[child] {
+					return branch, true
+				}
+				branch = child
+				visited[branch] = true
+			}
+		})
+	},
+}
+
+var bottomCmd = &cobra.Command{
+	Use:   "bottom",
+	Short: "⏬ Check out the first branch of the current stack",
+	Long:  `Check out the first branch above trunk in the current stack.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		navigate(args, func(printer *render.Printer, stack *core.BranchStack, current string, n int) (string, bool) {
+			if current == stack.MainBranch {
+				// On trunk every stack starts here, so ask which one
+				return pickChild(stack, current)
+			}
+			bottom := stack.BottomOf(current)
+			if bottom == current {
+				printer.Info(fmt.Sprintf("%s is already at the bottom of its stack", current))
+			}
+			return bottom, true
+		})
+	},
+}
+
+// navigate works out the branch to move to from the current one and checks it out.
+// An optional count argument is passed on as n, defaulting to 1.
+func navigate(args []string, target func(printer *render.Printer, stack *core.BranchStack, current string, n int) (string, bool)) {
+	printer := render.NewPrinter("stacksmith")
+	git := newGitExecutor()
+
+	n := 1
+	if len(args) > 0 {
+		count, err := strconv.Atoi(args[0])
+		if err != nil || count < 1 {
+			printer.Error(fmt.Sprintf("'%s' is not a number of branches to move", args[0]))
+			return
+		}
+		n = count
+	}
+
+	current, err := git.GetCurrentBranch()
+	if err != nil {
+		printer.Error(fmt.Sprintf("Error getting current branch: %s", err))
+		return
+	}
+
+	stack, err := git.BuildBranchStack()
+	if err != nil {
+		printer.Error(fmt.Sprintf("Error analyzing branch structure: %s", err))
+		return
+	}
+
+	node := stack.AllNodes[current]
+	if node == nil || (node.Parent == nil && len(node.Children) == 0) {
+		printer.ErrorWithSolution(
+			fmt.Sprintf("%s is not part of a stack", current),
+			"Run 'stacksmith graph' to see your stacks, or 'stacksmith doctor' to record missing parents",
+		)
+		return
+	}
+
+	branch, ok := target(printer, stack, current, n)
+	if !ok || branch == "" || branch == current {
+		return
+	}
+
+	if path := stack.AllNodes[branch].Worktree; path != "" {
+		printer.ErrorWithSolution(
+			fmt.Sprintf("%s is checked out in another worktree", branch),
+			fmt.Sprintf("Switch to %s to work on it", path),
+		)
+		return
+	}
+
+	if err := git.CheckoutBranch(branch); err != nil {
+		printer.HandleGitError(err)
+		return
+	}
+
+	if reportDryRun(printer, git) {
+		return
+	}
+
+	printer.Success(fmt.Sprintf("Checked out %s", branch))
+}
+
+// pickChild returns the child of branch to move to, asking which one when there are several.
+// It returns "" if branch has no children, and false if the user cancelled.
+func pickChild(stack *core.BranchStack, branch string) (string, bool) {
+	children := stack.ChildrenOf(branch)
+	switch len(children) {
+	case 0:
+		return "", true
+	case 1:
+		return children[0], true
+	}
+
+	return simplemenu.RunBranchPicker(
+		"🪜 Move up the stack",
+		fmt.Sprintf("%s has %d children, which one?", branch, len(children)),
+		children,
+	)
+}
+
+func init() {
+	rootCmd.AddCommand(upCmd)
+	rootCmd.AddCommand(downCmd)
+	rootCmd.AddCommand(topCmd)
+	rootCmd.AddCommand(bottomCmd)
+}
@@ -0,0 +1,274 @@
+// cmd/parallel.go
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mubbie/stacksmith/internal/core"
+	"github.com/mubbie/stacksmith/internal/render"
+)
+
+// restackStatus is the outcome of restacking a single branch
+type restackStatus int
+
+const (
+	statusRestacked restackStatus = iota
+	statusConflict
+	statusFailed
+	statusSkipped
+)
+
+// restackResult reports what happened to one step of a parallel restack
+type restackResult struct {
+	Step   core.RestackStep
+	Status restackStatus
+	Where  string // How the branch was restacked, for the per-branch output
+	Err    error
+}
+
+// runParallelRestack restacks independent subtrees concurrently, each branch in its own
+// temporary worktree, then pushes every restacked branch at once. The run is journaled like
+// a sequential sync: branches that couldn't be restacked are left for 'sync --continue' to
+// work through one at a time, and 'sync --abort' puts every branch back.
+func runParallelRestack(printer *render.Printer, git *core.GitExecutor, steps []core.RestackStep, jobs int) {
+	journal := core.NewOperationJournal("sync", "", steps)
+	if !prepareJournal(printer, git, journal) {
+		return
+	}
+
+	// The checked out branch is restacked in place, so it needs a clean working tree.
+	// Stashed last, so that no preflight failure leaves the changes behind in the stash.
+	for _, step := range journal.Steps {
+		if step.Branch == journal.OriginalBranch {
+			stash, ok := stashLocalChanges(printer, git, "sync")
+			if !ok {
+				return
+			}
+			journal.Stash = stash
+			break
+		}
+	}
+
+	if err := git.SaveJournal(journal); err != nil {
+		printer.Error(fmt.Sprintf("Error writing operation journal: %s", err))
+		restoreStash(printer, git, journal.Stash)
+		return
+	}
+
+	results := scheduleRestack(printer, git, journal.Steps, journal.OriginalBranch, jobs)
+
+	// Restacked steps go first, so the journal continues from the first one that wasn't.
+	// A step is only restacked once its parent was, so the order still has parents first.
+	var done, remaining []core.RestackStep
+	var restacked, failed, skipped []string
+	for _, result := range results {
+		switch result.Status {
+		case statusRestacked:
+			done = append(done, result.Step)
+			restacked = append(restacked, result.Step.Branch)
+		case statusSkipped:
+			remaining = append(remaining, result.Step)
+			skipped = append(skipped, result.Step.Branch)
+		default:
+			remaining = append(remaining, result.Step)
+			failed = append(failed, result.Step.Branch)
+		}
+	}
+	journal.Steps = append(done, remaining...)
+	journal.CurrentStep = len(done)
+
+	// Fork points are written one at a time, now that every parent is final
+	for _, step := range done {
+		if err := git.UpdateForkPoint(step.Branch, step.Parent); err != nil {
+			printer.Warning(fmt.Sprintf("Failed to record fork point for %s: %s", step.Branch, err))
+		}
+	}
+
+	if len(remaining) > 0 {
+		printer.RestackSummary(restacked, failed, skipped)
+		printer.Info("Continuing restacks the remaining branches one at a time in this checkout, so conflicts can be resolved")
+		pauseRestack(printer, git, journal)
+		return
+	}
+
+	if err := git.SaveJournal(journal); err != nil {
+		printer.Error(fmt.Sprintf("Error writing operation journal: %s", err))
+		return
+	}
+	runRestack(printer, git, journal)
+}
+
+// scheduleRestack runs each step once its parent has been restacked, with at most jobs
+// running at a time, printing each branch's outcome in plan order as it becomes known
+func scheduleRestack(printer *render.Printer, git *core.GitExecutor, steps []core.RestackStep, currentBranch string, jobs int) []restackResult {
+	// Map each branch to the step that restacks it, so children can find their parent's step
+	stepIndex := make(map[string]int)
+	for i, step := range steps {
+		stepIndex[step.Branch] = i
+	}
+
+	// Results are kept by step number, a branch name may not identify a single step
+	type finishedStep struct {
+		index  int
+		result restackResult
+	}
+	results := make([]*restackResult, len(steps))
+	started := make([]bool, len(steps))
+	done := make(chan finishedStep)
+	sem := make(chan struct{}, jobs)
+	running, finished, printed := 0, 0, 0
+
+	for finished < len(steps) {
+		// Start or skip every step whose parent is settled
+		for i, step := range steps {
+			if started[i] {
+				continue
+			}
+
+			if parentIndex, parentInPlan := stepIndex[step.Parent]; parentInPlan {
+				parentResult := results[parentIndex]
+				if parentResult == nil {
+					continue // Parent is still running
+				}
+				if parentResult.Status != statusRestacked {
+					started[i] = true
+					results[i] = &restackResult{Step: step, Status: statusSkipped}
+					finished++
+					continue
+				}
+			}
+
+			started[i] = true
+			running++
+			go func(i int, step core.RestackStep) {
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				done <- finishedStep{index: i, result: restackInWorktree(git, step, step.Branch == currentBranch)}
+			}(i, step)
+		}
+
+		// Print finished results in plan order
+		for printed < len(steps) && results[printed] != nil {
+			printRestackResult(printer, *results[printed])
+			printed++
+		}
+
+		if running == 0 {
+			continue // Only skipped steps were settled, look for more to start
+		}
+
+		step := <-done
+		running--
+		finished++
+		results[step.index] = &step.result
+	}
+
+	for printed < len(steps) {
+		printRestackResult(printer, *results[printed])
+		printed++
+	}
+
+	ordered := make([]restackResult, len(results))
+	for i, result := range results {
+		ordered[i] = *result
+	}
+	return ordered
+}
+
+// restackInWorktree restacks one branch without touching the main working tree, unless it
+// is the branch checked out there. Branches checked out in another worktree are rebased in
+// that worktree. Every call uses its own executor with git's settings so calls can run concurrently.
+func restackInWorktree(parent *core.GitExecutor, step core.RestackStep, checkedOut bool) restackResult {
+	git := parent.InWorktree(parent.WorkDir)
+	result := restackResult{Step: step}
+
+	if step.Worktree != "" {
+		git = parent.InWorktree(step.Worktree)
+		checkedOut = true
+
+		dirty, err := git.IsWorkingTreeDirty()
+		if err != nil {
+			result.Status, result.Err = statusFailed, err
+			return result
+		}
+		if dirty {
+			result.Status, result.Err = statusFailed, fmt.Errorf("%s has uncommitted changes", step.Worktree)
+			return result
+		}
+	}
+
+	// A real rebase is only needed when merge-tree can't do it, a conflict would stop that too
+	if !checkedOut {
+		err := git.RestackInMemory(step.Branch, step.Parent, step.Upstream)
+		if err == nil {
+			result.Where = "in memory"
+			return result
+		}
+		if !errors.Is(err, core.ErrInMemoryUnsupported) {
+			return failedResult(result, err)
+		}
+	}
+
+	worktree := git
+	result.Where = "in the current checkout"
+	if step.Worktree != "" {
+		result.Where = "in " + step.Worktree
+	}
+
+	if !checkedOut {
+		tmp, err := os.MkdirTemp("", "stacksmith-")
+		if err != nil {
+			result.Status, result.Err = statusFailed, err
+			return result
+		}
+		dir := filepath.Join(tmp, strings.ReplaceAll(step.Branch, "/", "-"))
+
+		if err := git.AddWorktree(dir, step.Branch); err != nil {
+			os.RemoveAll(tmp)
+			result.Status, result.Err = statusFailed, err
+			return result
+		}
+		defer func() {
+			git.RemoveWorktree(dir)
+			os.RemoveAll(tmp)
+		}()
+
+		worktree = parent.InWorktree(dir)
+		result.Where = "in a temporary worktree"
+	}
+
+	if err := worktree.RebaseBranchOnto(step.Parent, step.Upstream); err != nil {
+		// Leave the branch as it was so the user can resolve it with a sequential sync
+		worktree.AbortRebase()
+		return failedResult(result, err)
+	}
+	return result
+}
+
+// failedResult marks a result as failed, or as a conflict if err is one
+func failedResult(result restackResult, err error) restackResult {
+	result.Status, result.Err = statusFailed, err
+	if _, ok := err.(*core.MergeConflictError); ok {
+		result.Status = statusConflict
+	}
+	return result
+}
+
+// printRestackResult prints the outcome of a single branch
+func printRestackResult(printer *render.Printer, result restackResult) {
+	step := result.Step
+	switch result.Status {
+	case statusRestacked:
+		printer.Success(fmt.Sprintf("Rebased %s onto %s %s", step.Branch, step.Parent, result.Where))
+	case statusConflict:
+		printer.Error(fmt.Sprintf("Merge conflict when rebasing %s onto %s, left unchanged", step.Branch, step.Parent))
+	case statusFailed:
+		printer.Error(fmt.Sprintf("Error rebasing %s onto %s: %s", step.Branch, step.Parent, result.Err))
+	case statusSkipped:
+		printer.Warning(fmt.Sprintf("Skipped %s because %s was not restacked", step.Branch, step.Parent))
+	}
+}
@@ -3,19 +3,27 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/mubbie/stacksmith/internal/core"
 	"github.com/mubbie/stacksmith/internal/render"
 	"github.com/spf13/cobra"
 )
 
+var pushWholeStack bool
+
 var pushCmd = &cobra.Command{
 	Use:   "push",
 	Short: "⬆️ Smart push with upstream detection",
-	Long:  `Push the current branch with upstream handling.`,
+	Long: `Push the current branch with upstream handling.
+
+With --stack, every branch in the current stack that differs from the remote
+is pushed in a single atomic push, so either all of them update or none do.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		defer finishOperation()
+
 		printer := render.NewPrinter("stacksmith")
-		git := core.NewGitExecutor("")
+		git := newGitExecutor()
 
 		currentBranch, err := git.GetCurrentBranch()
 		if err != nil {
@@ -23,12 +31,21 @@ var pushCmd = &cobra.Command{
 			return
 		}
 
+		if pushWholeStack {
+			pushCurrentStack(printer, git, currentBranch)
+			return
+		}
+
 		hasUpstream, err := git.HasUpstream()
 		if err != nil {
 			printer.Error(fmt.Sprintf("Error checking upstream: %s", err))
 			return
 		}
 
+		if !recordOperation(printer, git, "push "+currentBranch) {
+			return
+		}
+
 		// Show a spinner or progress indicator
 		printer.Info(fmt.Sprintf("Pushing branch %s...", currentBranch))
 
@@ -38,6 +55,9 @@ var pushCmd = &cobra.Command{
 				printer.Error(fmt.Sprintf("Error pushing branch: %s", err))
 				return
 			}
+			if reportDryRun(printer, git) {
+				return
+			}
 			printer.PushSuccess(currentBranch)
 		} else {
 			err = git.SetUpstreamBranch(currentBranch)
@@ -45,11 +65,66 @@ var pushCmd = &cobra.Command{
 				printer.Error(fmt.Sprintf("Error setting upstream: %s", err))
 				return
 			}
+			if reportDryRun(printer, git) {
+				return
+			}
 			printer.NewUpstreamSuccess(currentBranch)
 		}
 	},
 }
 
+// pushCurrentStack atomically pushes the branches of the current stack that changed
+func pushCurrentStack(printer *render.Printer, git *core.GitExecutor, currentBranch string) {
+	stack, err := git.BuildBranchStack()
+	if err != nil {
+		printer.Error(fmt.Sprintf("Error building branch stack: %s", err))
+		return
+	}
+
+	branches, err := stack.StackOf(currentBranch)
+	if err != nil {
+		printer.HandleGitError(err)
+		return
+	}
+	if len(branches) == 0 {
+		printer.Info(fmt.Sprintf("%s is not part of a stack", currentBranch))
+		return
+	}
+
+	changed, err := git.ChangedBranches(branches)
+	if err != nil {
+		printer.Error(fmt.Sprintf("Error comparing branches with the remote: %s", err))
+		return
+	}
+	if len(changed) == 0 {
+		printer.Info("Every branch in the stack is already up to date on the remote")
+		return
+	}
+
+	if !recordOperation(printer, git, "push --stack "+currentBranch) {
+		return
+	}
+
+	var names []string
+	for _, candidate := range changed {
+		names = append(names, candidate.Branch)
+	}
+	printer.Info(fmt.Sprintf("Pushing %s (%d unchanged)...", strings.Join(names, ", "), len(branches)-len(changed)))
+
+	if err := git.PushBranchesAtomic(changed); err != nil {
+		printer.Error(fmt.Sprintf("Error pushing stack, no branches were updated: %s", err))
+		return
+	}
+	if reportDryRun(printer, git) {
+		return
+	}
+
+	for _, name := range names {
+		printer.PushSuccess(name)
+	}
+}
+
 func init() {
+	pushCmd.Flags().BoolVar(&pushWholeStack, "stack", false, "Atomically push every changed branch in the current stack")
 	rootCmd.AddCommand(pushCmd)
 }
@@ -0,0 +1,586 @@
+// cmd/restack.go
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mubbie/stacksmith/internal/config"
+	"github.com/mubbie/stacksmith/internal/core"
+	"github.com/mubbie/stacksmith/internal/render"
+	"github.com/spf13/cobra"
+)
+
+// Flags shared by every command that restacks branches
+var (
+	continueOperation bool
+	abortOperation    bool
+)
+
+// addResumeFlags registers --continue and --abort on a restacking command
+func addResumeFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&continueOperation, "continue", false, "Resume an operation that stopped on a conflict")
+	cmd.Flags().BoolVar(&abortOperation, "abort", false, "Abort an interrupted operation and restore every branch")
+	cmd.MarkFlagsMutuallyExclusive("continue", "abort")
+}
+
+// handleResumeFlags runs --continue or --abort if requested, returning true if it did
+func handleResumeFlags(printer *render.Printer, git *core.GitExecutor) bool {
+	operationChanged = operationChanged || continueOperation || abortOperation
+	switch {
+	case continueOperation:
+		resumeRestack(printer, git)
+		return true
+	case abortOperation:
+		abortRestack(printer, git)
+		return true
+	}
+	return false
+}
+
+// noOperationInProgress checks that no interrupted restack is waiting to be continued or aborted
+func noOperationInProgress(printer *render.Printer, git *core.GitExecutor) bool {
+	existing, err := git.LoadJournal()
+	if err != nil {
+		printer.Error(fmt.Sprintf("Error reading operation journal: %s", err))
+		return false
+	}
+	if existing != nil {
+		printer.ErrorWithSolution(
+			fmt.Sprintf("An interrupted %s is still in progress", existing.Command),
+			fmt.Sprintf("Run 'stacksmith %s --continue' or 'stacksmith %s --abort' first", existing.Command, existing.Command),
+		)
+		return false
+	}
+	return true
+}
+
+// startRestack journals and runs a new restack operation
+func startRestack(printer *render.Printer, git *core.GitExecutor, command string, steps []core.RestackStep) {
+	startJournal(printer, git, core.NewOperationJournal(command, "", steps))
+}
+
+// startJournal fills in and runs a new operation journal, for commands that record more
+// than the steps to restack
+func startJournal(printer *render.Printer, git *core.GitExecutor, journal *core.OperationJournal) {
+	if !prepareJournal(printer, git, journal) {
+		return
+	}
+
+	// Stash last, so that nothing can stop the operation between stashing and journaling it
+	stash, ok := stashLocalChanges(printer, git, journal.Command)
+	if !ok {
+		return
+	}
+	journal.Stash = stash
+
+	saveAndRunJournal(printer, git, journal)
+}
+
+// prepareJournal fetches and resolves everything a new operation needs before anything is
+// changed, returning false if the operation should not go ahead. Steps whose fork point
+// was already resolved by the caller keep it.
+func prepareJournal(printer *render.Printer, git *core.GitExecutor, journal *core.OperationJournal) bool {
+	if !noOperationInProgress(printer, git) {
+		return false
+	}
+
+	if journal.OriginalBranch == "" {
+		originalBranch, err := git.GetCurrentBranch()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error getting current branch: %s", err))
+			return false
+		}
+		journal.OriginalBranch = originalBranch
+	}
+
+	if err := git.FetchRemote(); err != nil {
+		printer.Error(fmt.Sprintf("Error fetching remote: %s", err))
+		return false
+	}
+
+	// Kept so that an abort can put the recorded stack back as well as the branches
+	original, err := git.LoadStackConfig()
+	if err != nil {
+		printer.Error(fmt.Sprintf("Error reading stack relationships: %s", err))
+		return false
+	}
+	journal.OriginalStack = original
+
+	// Branches checked out in other worktrees are rebased there instead of here
+	worktreeBranches, err := git.OtherWorktreeBranches()
+	if err != nil {
+		printer.HandleGitError(err)
+		return false
+	}
+
+	for i, step := range journal.Steps {
+		journal.Steps[i].Worktree = worktreeBranches[step.Branch]
+
+		sha, err := git.GetBranchSHA(step.Branch)
+		if err != nil {
+			printer.HandleGitError(err)
+			return false
+		}
+		journal.OriginalSHAs[step.Branch] = sha
+
+		// Resolve fork points up front, before any parent in the stack moves
+		if step.Upstream != "" {
+			continue
+		}
+		upstream, err := git.ForkPoint(step.Branch, step.Parent)
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error finding where %s forked from %s: %s", step.Branch, step.Parent, err))
+			return false
+		}
+		journal.Steps[i].Upstream = upstream
+	}
+	return true
+}
+
+// saveAndRunJournal saves a prepared journal and runs it, restoring the stash if it can't be saved
+func saveAndRunJournal(printer *render.Printer, git *core.GitExecutor, journal *core.OperationJournal) {
+	if err := git.SaveJournal(journal); err != nil {
+		printer.Error(fmt.Sprintf("Error writing operation journal: %s", err))
+		restoreStash(printer, git, journal.Stash)
+		return
+	}
+
+	runRestack(printer, git, journal)
+}
+
+// runRestack works through the journal from its current step, saving progress as it goes
+func runRestack(printer *render.Printer, git *core.GitExecutor, journal *core.OperationJournal) {
+	for !journal.Done() {
+		step := journal.Current()
+
+		if !journal.StepRebased {
+			printer.RebaseStart(step.Branch, step.Parent)
+
+			if !restackStep(printer, git, step) {
+				pauseRestack(printer, git, journal)
+				return
+			}
+
+			if !completeRebase(printer, git, journal) {
+				return
+			}
+		}
+
+		journal.Advance()
+		if err := git.SaveJournal(journal); err != nil {
+			printer.Error(fmt.Sprintf("Error writing operation journal: %s", err))
+			return
+		}
+	}
+
+	// Push everything in one go so the remote never ends up with half a stack. Every rebase is
+	// done and its fork point recorded, so a failed push doesn't hold the operation open.
+	branches := append([]string{}, journal.PushAlso...)
+	for _, step := range journal.Steps {
+		branches = append(branches, step.Branch)
+	}
+	pushed := pushStack(printer, git, branches)
+
+	// Only once the branches built on them are pushed, so their PRs aren't closed early
+	if pushed {
+		for _, branch := range journal.DeleteRemote {
+			if err := git.DeleteRemoteBranch(branch); err != nil {
+				printer.Warning(fmt.Sprintf("Failed to delete %s on the remote: %s", branch, err))
+			} else if !git.DryRun {
+				printer.Success(fmt.Sprintf("Deleted %s on the remote", branch))
+			}
+		}
+	}
+
+	if err := git.ClearJournal(); err != nil {
+		printer.Warning(fmt.Sprintf("Failed to remove operation journal: %s", err))
+	}
+
+	restoreWorkspace(printer, git, journal)
+
+	if reportDryRun(printer, git) {
+		return
+	}
+
+	if !pushed {
+		printer.ErrorWithSolution(
+			fmt.Sprintf("Every branch of the %s was restacked, but pushing them failed", journal.Command),
+			"Once the problem is fixed, run 'stacksmith push --stack' from a branch in each stack: "+strings.Join(branches, ", "),
+		)
+		for _, branch := range journal.DeleteRemote {
+			printer.Info(fmt.Sprintf("Kept %s on the remote, delete it once the branches built on it are pushed", branch))
+		}
+		return
+	}
+
+	finishRestack(printer, journal)
+}
+
+// stashLocalChanges stashes uncommitted changes before an operation, asking first unless
+// stacksmith.autoStash is set. Returns the stash commit and false if the operation should not go ahead.
+func stashLocalChanges(printer *render.Printer, git *core.GitExecutor, command string) (string, bool) {
+	dirty, err := git.HasLocalChanges()
+	if err != nil {
+		printer.HandleGitError(err)
+		return "", false
+	}
+	if !dirty {
+		return "", true
+	}
+
+	cfg, err := config.Load(git)
+	if err != nil {
+		printer.Error(fmt.Sprintf("Error reading configuration: %s", err))
+		return "", false
+	}
+
+	if !cfg.AutoStash && !confirm("You have uncommitted changes. Stash them while the "+command+" runs?") {
+		printer.ErrorWithSolution(
+			fmt.Sprintf("Cannot %s with uncommitted changes", command),
+			"Commit or stash your changes, or set 'git config stacksmith.autoStash true' to stash automatically",
+		)
+		return "", false
+	}
+
+	stash, err := git.StashChanges("stacksmith: " + command)
+	if err != nil {
+		printer.HandleGitError(err)
+		return "", false
+	}
+
+	printer.Info("Stashed your uncommitted changes, they will be restored when the " + command + " finishes")
+	return stash, true
+}
+
+// restoreWorkspace returns to the branch the operation started on and reapplies stashed changes
+func restoreWorkspace(printer *render.Printer, git *core.GitExecutor, journal *core.OperationJournal) {
+	currentBranch, err := git.GetCurrentBranch()
+	if err == nil && currentBranch != journal.OriginalBranch {
+		if err := git.CheckoutBranch(journal.OriginalBranch); err != nil {
+			printer.Warning(fmt.Sprintf("Could not return to %s: %s", journal.OriginalBranch, err))
+		}
+	}
+
+	restoreStash(printer, git, journal.Stash)
+}
+
+// restoreStash reapplies changes stashed by stashLocalChanges, if there were any
+func restoreStash(printer *render.Printer, git *core.GitExecutor, stash string) {
+	if stash == "" {
+		return
+	}
+
+	if err := git.RestoreStash(stash); err != nil {
+		printer.HandleGitError(err)
+		return
+	}
+	printer.Info("Restored your uncommitted changes")
+}
+
+// restackStep rebases a branch onto its parent, in memory when possible and with a
+// real checkout otherwise, returning false if the step stopped
+func restackStep(printer *render.Printer, git *core.GitExecutor, step core.RestackStep) bool {
+	if step.Worktree != "" {
+		return restackInOtherWorktree(printer, git, step)
+	}
+
+	if step.Upstream != "" {
+		err := git.RestackInMemory(step.Branch, step.Parent, step.Upstream)
+		if err == nil {
+			return true
+		}
+
+		// Conflicts need a working tree to be resolved in
+		if _, ok := err.(*core.MergeConflictError); ok {
+			printer.Info(fmt.Sprintf("%s has conflicts with %s, checking it out to resolve them", step.Branch, step.Parent))
+		}
+	}
+
+	err := git.CheckoutBranch(step.Branch)
+	if err != nil {
+		printer.Error(fmt.Sprintf("Error checking out %s: %s", step.Branch, err))
+		return false
+	}
+
+	if step.Upstream != "" {
+		err = git.RebaseBranchOnto(step.Parent, step.Upstream)
+	} else {
+		err = git.RebaseBranch(step.Parent)
+	}
+	if err != nil {
+		reportRebaseError(printer, step, err)
+		return false
+	}
+
+	return true
+}
+
+// restackInOtherWorktree rebases a branch in the worktree that has it checked out,
+// since git won't check out a branch in two worktrees at once
+func restackInOtherWorktree(printer *render.Printer, git *core.GitExecutor, step core.RestackStep) bool {
+	worktree := git.InWorktree(step.Worktree)
+
+	dirty, err := worktree.IsWorkingTreeDirty()
+	if err != nil {
+		printer.HandleGitError(err)
+		return false
+	}
+	if dirty {
+		printer.ErrorWithSolution(
+			fmt.Sprintf("%s is checked out in %s with uncommitted changes", step.Branch, step.Worktree),
+			"Commit or stash the changes in that worktree first",
+		)
+		return false
+	}
+
+	printer.Info(fmt.Sprintf("%s is checked out in %s, rebasing it there", step.Branch, step.Worktree))
+
+	if step.Upstream != "" {
+		err = worktree.RebaseBranchOnto(step.Parent, step.Upstream)
+	} else {
+		err = worktree.RebaseBranch(step.Parent)
+	}
+
+	// Keep the dry run plan in one place
+	git.Plan = append(git.Plan, worktree.Plan...)
+
+	if err != nil {
+		reportRebaseError(printer, step, err)
+		if _, ok := err.(*core.MergeConflictError); ok {
+			printer.Info(fmt.Sprintf("Resolve the conflicts in %s and stage them, then continue from this worktree", step.Worktree))
+		}
+		return false
+	}
+	return true
+}
+
+// stepExecutor returns the executor for the worktree a step's branch is rebased in
+func stepExecutor(git *core.GitExecutor, step core.RestackStep) *core.GitExecutor {
+	if step.Worktree != "" {
+		return git.InWorktree(step.Worktree)
+	}
+	return git
+}
+
+// completeRebase records the new fork point of the current step and saves the journal
+func completeRebase(printer *render.Printer, git *core.GitExecutor, journal *core.OperationJournal) bool {
+	step := journal.Current()
+
+	// fix-pr rebases onto the remote target, which is never a recorded parent, the local one is
+	var err error
+	if journal.Command == "fix-pr" {
+		err = git.RecordRebasedOnto(step.Branch, strings.TrimPrefix(step.Parent, "origin/"), step.Parent)
+	} else {
+		err = git.UpdateForkPoint(step.Branch, step.Parent)
+	}
+	if err != nil {
+		printer.Warning(fmt.Sprintf("Failed to record fork point for %s: %s", step.Branch, err))
+	}
+
+	journal.StepRebased = true
+	if err := git.SaveJournal(journal); err != nil {
+		printer.Error(fmt.Sprintf("Error writing operation journal: %s", err))
+		return false
+	}
+	return true
+}
+
+// reportRebaseError prints a rebase failure in terms of the step being restacked
+func reportRebaseError(printer *render.Printer, step core.RestackStep, err error) {
+	if _, ok := err.(*core.MergeConflictError); ok {
+		// The branch is detached mid-rebase, so name it from the step instead
+		printer.Error(fmt.Sprintf("Merge conflict when rebasing %s onto %s", step.Branch, step.Parent))
+		return
+	}
+	printer.HandleGitError(err)
+}
+
+// pauseRestack saves the journal and tells the user how to carry on
+func pauseRestack(printer *render.Printer, git *core.GitExecutor, journal *core.OperationJournal) {
+	if err := git.SaveJournal(journal); err != nil {
+		printer.Error(fmt.Sprintf("Error writing operation journal: %s", err))
+		return
+	}
+	printer.OperationPaused(journal.Command)
+}
+
+// finishRestack prints the command specific completion message
+func finishRestack(printer *render.Printer, journal *core.OperationJournal) {
+	switch journal.Command {
+	case "fix-pr":
+		for _, step := range journal.Steps {
+			target := strings.TrimPrefix(step.Parent, "origin/")
+			printer.Success(fmt.Sprintf("Successfully rebased %s onto %s", step.Branch, target))
+			printer.RetargetReminder(step.Branch, target)
+		}
+	case "insert":
+		printer.Success(fmt.Sprintf("Restacked %d branch(es) onto %s", len(journal.Steps), journal.Steps[0].Parent))
+	case "move":
+		moved := journal.Steps[0]
+		printer.Success(fmt.Sprintf("Moved %s onto %s and restacked %d branch(es)", moved.Branch, moved.Parent, len(journal.Steps)))
+
+		// Only the moved branch's PR changes base, its descendants still target the same branches
+		printer.RetargetReminder(moved.Branch, moved.Parent)
+	case "fold":
+		if len(journal.Steps) == 0 {
+			return
+		}
+		parent := journal.Steps[0].Parent
+		printer.Success(fmt.Sprintf("Restacked %d branch(es) onto %s", len(journal.Steps), parent))
+		for _, step := range journal.Steps {
+			if step.Parent == parent {
+				printer.RetargetReminder(step.Branch, parent)
+			}
+		}
+	default:
+		printer.Success("Stack sync complete!")
+	}
+}
+
+// resumeRestack continues an interrupted operation from the journal
+func resumeRestack(printer *render.Printer, git *core.GitExecutor) {
+	journal, err := git.LoadJournal()
+	if err != nil {
+		printer.Error(fmt.Sprintf("Error reading operation journal: %s", err))
+		return
+	}
+	if journal == nil {
+		printer.Info("No interrupted operation to continue.")
+		return
+	}
+
+	printer.Info(fmt.Sprintf("Resuming %s at step %d of %d", journal.Command, journal.CurrentStep+1, len(journal.Steps)))
+
+	var rebasing bool
+	worktree := git
+	if !journal.Done() {
+		worktree = stepExecutor(git, journal.Current())
+		rebasing, err = worktree.IsRebaseInProgress()
+		if err != nil {
+			printer.HandleGitError(err)
+			return
+		}
+	}
+
+	if rebasing {
+		err = worktree.ContinueRebase()
+		if err != nil {
+			reportRebaseError(printer, journal.Current(), err)
+			printer.OperationPaused(journal.Command)
+			return
+		}
+
+		if !completeRebase(printer, git, journal) {
+			return
+		}
+	}
+
+	runRestack(printer, git, journal)
+}
+
+// abortRestack rolls every branch touched by an interrupted operation back to its original commit
+func abortRestack(printer *render.Printer, git *core.GitExecutor) {
+	journal, err := git.LoadJournal()
+	if err != nil {
+		printer.Error(fmt.Sprintf("Error reading operation journal: %s", err))
+		return
+	}
+	if journal == nil {
+		printer.Info("No interrupted operation to abort.")
+		return
+	}
+
+	if !journal.Done() {
+		worktree := stepExecutor(git, journal.Current())
+		rebasing, err := worktree.IsRebaseInProgress()
+		if err != nil {
+			printer.HandleGitError(err)
+			return
+		}
+
+		if rebasing {
+			if err := worktree.AbortRebase(); err != nil {
+				printer.HandleGitError(err)
+				return
+			}
+		}
+	}
+
+	if err := git.CheckoutBranch(journal.OriginalBranch); err != nil {
+		printer.HandleGitError(err)
+		return
+	}
+
+	restacked := make(map[string]bool)
+	for _, step := range journal.Steps {
+		restacked[step.Branch] = true
+		sha, ok := journal.OriginalSHAs[step.Branch]
+		if !ok {
+			continue
+		}
+
+		if err := stepExecutor(git, step).ResetBranch(step.Branch, sha); err != nil {
+			printer.Error(fmt.Sprintf("Error restoring %s: %s", step.Branch, err))
+			return
+		}
+	}
+
+	// Branches the command changed before restacking, such as a folded branch and its parent
+	var changed []string
+	for branch := range journal.OriginalSHAs {
+		if !restacked[branch] {
+			changed = append(changed, branch)
+		}
+	}
+	sort.Strings(changed)
+	for _, branch := range changed {
+		if err := git.ResetBranch(branch, journal.OriginalSHAs[branch]); err != nil {
+			printer.Error(fmt.Sprintf("Error restoring %s: %s", branch, err))
+			return
+		}
+	}
+
+	for _, branch := range journal.Created {
+		if err := git.DeleteBranch(branch); err != nil {
+			printer.Error(fmt.Sprintf("Error removing %s: %s", branch, err))
+			return
+		}
+	}
+
+	if journal.OriginalStack != nil {
+		if err := git.SaveStackConfig(journal.OriginalStack); err != nil {
+			printer.Error(fmt.Sprintf("Error restoring stack relationships: %s", err))
+			return
+		}
+	}
+
+	if err := git.ClearJournal(); err != nil {
+		printer.Warning(fmt.Sprintf("Failed to remove operation journal: %s", err))
+	}
+
+	restoreWorkspace(printer, git, journal)
+
+	if reportDryRun(printer, git) {
+		return
+	}
+
+	printer.Success(fmt.Sprintf("Aborted %s and restored all branches", journal.Command))
+}
+
+// pushStack atomically pushes whichever branches changed, returning false if the push failed
+func pushStack(printer *render.Printer, git *core.GitExecutor, branches []string) bool {
+	pushed, err := git.PushChangedBranches(branches)
+	if err != nil {
+		printer.Error(fmt.Sprintf("Error pushing %s: %s", strings.Join(branches, ", "), err))
+		return false
+	}
+
+	if !git.DryRun {
+		for _, branch := range pushed {
+			printer.PushSuccess(branch)
+		}
+	}
+	return true
+}
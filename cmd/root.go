@@ -5,6 +5,9 @@ import (
 	"os"
 	"strings"
 
+	"github.com/mubbie/stacksmith/internal/config"
+	"github.com/mubbie/stacksmith/internal/core"
+	"github.com/mubbie/stacksmith/internal/render"
 	"github.com/mubbie/stacksmith/internal/ui/simplemenu"
 	"github.com/spf13/cobra"
 )
@@ -15,6 +18,15 @@ var (
 	BuildTime = "unknown"
 )
 
+// Global flags
+var (
+	dryRun  bool
+	noCache bool
+)
+
+// operationChanged is set once a command records, continues or aborts an operation, until it finishes
+var operationChanged bool
+
 var rootCmd = &cobra.Command{
 	Use:   "stacksmith",
 	Short: "🧑🏾‍🏭 Stacksmith - Artisan Git Stacking Tool",
@@ -42,6 +54,9 @@ func init() {
 Build Time: ` + BuildTime + `
 `)
 
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Show what would be changed without changing anything")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Work out the stack from scratch instead of using cached results")
+
 	// Hide the completion command from help
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 
@@ -78,7 +93,7 @@ func launchMainMenu() {
 		case "stack":
 			stackCmd.Run(nil, []string{})
 		case "sync":
-			syncCmd.Run(nil, []string{})
+			runInteractiveSync()
 		case "fix-pr":
 			fixPrCmd.Run(nil, []string{})
 		case "push":
@@ -93,15 +108,84 @@ func launchMainMenu() {
 
 		// Ask user whether to return to menu or exit
 		fmt.Println()
-		fmt.Print("Would you like to return to the Stacksmith menu? [Y/n]: ")
-		var response string
-		fmt.Scanln(&response)
-
-		response = strings.ToLower(strings.TrimSpace(response))
-		if response == "n" || response == "no" {
+		if !confirm("Would you like to return to the Stacksmith menu?") {
 			return // Exit the function, which exits the application
 		}
 
 		fmt.Println() // Add extra newline for spacing
 	}
 }
+
+// newGitExecutor creates a git executor configured from the global flags
+func newGitExecutor() *core.GitExecutor {
+	git := core.NewGitExecutor("")
+	git.DryRun = dryRun
+	git.NoCache = noCache
+
+	// Use the stack store picked in git config, or stack.yml if it can't be read
+	cfg, err := config.Load(git)
+	if err != nil {
+		return git
+	}
+	store, err := core.NewStackStore(cfg.Store, git)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v, using stack.yml\n", err)
+		return git
+	}
+	git.Store = store
+
+	return git
+}
+
+// reportDryRun prints the recorded plan of a dry run, returning true if this was one
+func reportDryRun(printer *render.Printer, git *core.GitExecutor) bool {
+	if !git.DryRun {
+		return false
+	}
+	printer.DryRunPlan(git.Plan)
+	return true
+}
+
+// recordOperation snapshots every branch before a mutating command so it can be undone,
+// returning false if the command should not go ahead
+func recordOperation(printer *render.Printer, git *core.GitExecutor, command string) bool {
+	if _, err := git.SnapshotOperation(command); err != nil {
+		printer.Error(fmt.Sprintf("Error recording operation for undo: %s", err))
+		return false
+	}
+	operationChanged = true
+	return true
+}
+
+// finishOperation records where the operation a command recorded, continued or aborted left
+// every branch. Commands defer it, so that each operation run from the menu is finished too.
+func finishOperation() {
+	if !operationChanged {
+		return
+	}
+	operationChanged = false
+
+	if err := newGitExecutor().FinishOperation(); err != nil {
+		render.NewPrinter("stacksmith").Warning(fmt.Sprintf("Failed to record the outcome for undo: %s", err))
+	}
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to yes
+func confirm(question string) bool {
+	fmt.Printf("%s [Y/n]: ", question)
+	var response string
+	fmt.Scanln(&response)
+
+	response = strings.ToLower(strings.TrimSpace(response))
+	return response != "n" && response != "no"
+}
+
+// confirmNo asks a yes/no question on the terminal, defaulting to no
+func confirmNo(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	var response string
+	fmt.Scanln(&response)
+
+	response = strings.ToLower(strings.TrimSpace(response))
+	return response == "y" || response == "yes"
+}
@@ -0,0 +1,131 @@
+// cmd/split.go
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mubbie/stacksmith/internal/core"
+	"github.com/mubbie/stacksmith/internal/render"
+	"github.com/mubbie/stacksmith/internal/ui/simplemenu"
+	"github.com/spf13/cobra"
+)
+
+var splitCmd = &cobra.Command{
+	Use:   "split [branch]",
+	Short: "✂️ Break a branch into several stacked branches",
+	Long: `Show the commits a branch has on top of its recorded parent, mark where to split
+them and name a branch for each piece. The pieces are stacked on each other in order,
+and the branch's children move onto the last piece.
+
+No commits are rewritten, each piece is a branch at one of the original commits.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		defer finishOperation()
+
+		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
+
+		var branch string
+		if len(args) > 0 {
+			branch = args[0]
+		} else {
+			current, err := git.GetCurrentBranch()
+			if err != nil {
+				printer.Error(fmt.Sprintf("Error getting current branch: %s", err))
+				return
+			}
+			branch = current
+		}
+
+		stack, err := git.BuildBranchStack()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error analyzing branch structure: %s", err))
+			return
+		}
+
+		if stack.AllNodes[branch] == nil {
+			printer.HandleGitError(&core.BranchNotFoundError{BranchName: branch})
+			return
+		}
+		parent := stack.ParentOf(branch)
+		if parent == "" {
+			printer.ErrorWithSolution(
+				fmt.Sprintf("%s has no recorded parent to split it against", branch),
+				"Run 'stacksmith doctor' to record missing parents",
+			)
+			return
+		}
+
+		base, err := git.ForkPoint(branch, parent)
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error finding where %s forked from %s: %s", branch, parent, err))
+			return
+		}
+		commits, err := git.BranchCommits(branch, base)
+		if err != nil {
+			printer.HandleGitError(err)
+			return
+		}
+		if len(commits) < 2 {
+			printer.Error(fmt.Sprintf("%s has %d commit(s) on top of %s, nothing to split", branch, len(commits), parent))
+			return
+		}
+
+		labels := make([]string, len(commits))
+		for i, commit := range commits {
+			labels[i] = fmt.Sprintf("%.7s %s", commit.SHA, commit.Subject)
+		}
+		ends, names, ok := simplemenu.RunSplitPrompt(branch, labels)
+		if !ok {
+			return
+		}
+
+		pieces := make([]core.SplitPiece, len(ends))
+		for i, end := range ends {
+			pieces[i] = core.SplitPiece{Name: names[i], Commit: commits[end].SHA}
+
+			if names[i] == branch {
+				continue
+			}
+			if stack.AllNodes[names[i]] != nil {
+				printer.Error(fmt.Sprintf("A branch named %s already exists", names[i]))
+				return
+			}
+			if !git.IsValidBranchName(names[i]) {
+				printer.Error(fmt.Sprintf("'%s' is not a valid branch name", names[i]))
+				return
+			}
+		}
+
+		if !recordOperation(printer, git, fmt.Sprintf("split %s", branch)) {
+			return
+		}
+
+		children, err := git.SplitBranch(branch, parent, base, pieces)
+		if err != nil {
+			printer.HandleGitError(err)
+			return
+		}
+
+		if reportDryRun(printer, git) {
+			return
+		}
+
+		printer.Success(fmt.Sprintf("Split %s into %s", branch, strings.Join(names, " → ")))
+		last := names[len(names)-1]
+		if len(children) > 0 {
+			printer.Info(fmt.Sprintf("Moved %s onto %s", strings.Join(children, ", "), last))
+		}
+		if last == branch {
+			printer.RetargetReminder(branch, names[len(names)-2])
+		} else {
+			printer.Info(fmt.Sprintf("%s is no longer part of the stack, delete it once its PR is closed", branch))
+		}
+		printer.Info("Push the new branches with 'stacksmith push --stack' and open a PR for each")
+	},
+	Args: cobra.MaximumNArgs(1),
+}
+
+func init() {
+	rootCmd.AddCommand(splitCmd)
+}
@@ -4,7 +4,6 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/mubbie/stacksmith/internal/core"
 	"github.com/mubbie/stacksmith/internal/render"
 	"github.com/mubbie/stacksmith/internal/ui/simplemenu"
 	"github.com/spf13/cobra"
@@ -15,6 +14,7 @@ var stackCmd = &cobra.Command{
 	Short: "🪵 Create a new branch atop another",
 	Long:  `Forge a new stacked branch on top of an existing parent branch.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		defer finishOperation()
 		var newBranch, parentBranch string
 		var success bool
 
@@ -32,7 +32,11 @@ var stackCmd = &cobra.Command{
 			parentBranch = args[1]
 		}
 
-		git := core.NewGitExecutor("")
+		git := newGitExecutor()
+
+		if !recordOperation(printer, git, fmt.Sprintf("stack %s %s", newBranch, parentBranch)) {
+			return
+		}
 
 		err := git.CreateBranch(newBranch, parentBranch)
 		if err != nil {
@@ -40,6 +44,10 @@ var stackCmd = &cobra.Command{
 			return
 		}
 
+		if reportDryRun(printer, git) {
+			return
+		}
+
 		printer.ForgeSuccess(newBranch, parentBranch)
 	},
 	Args: cobra.MaximumNArgs(2),
@@ -0,0 +1,269 @@
+// cmd/stacks.go
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mubbie/stacksmith/internal/core"
+	"github.com/mubbie/stacksmith/internal/render"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stacksMine        bool
+	stacksBranch      string
+	stacksDescription string
+	stacksOwner       string
+	stacksIssue       string
+)
+
+var stacksCmd = &cobra.Command{
+	Use:   "stacks",
+	Short: "📚 Name your stacks and see who owns what",
+	Long: `Give a stack a name, description, owner and linked issue.
+
+A stack is a branch just above trunk together with everything stacked on it.`,
+}
+
+var stacksListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "📋 List named and unnamed stacks",
+	Run: func(cmd *cobra.Command, args []string) {
+		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
+
+		stack, err := git.BuildBranchStack()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error analyzing branch structure: %s", err))
+			return
+		}
+
+		named, err := git.LoadNamedStacks()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error reading stacks: %s", err))
+			return
+		}
+
+		me := git.CurrentUser()
+		listed := 0
+		for _, ns := range named {
+			if stacksMine && ns.Owner != me {
+				continue
+			}
+			if listed == 0 {
+				printer.Info("Named stacks:")
+			}
+			listed++
+			printer.BulletPoint(describeNamedStack(stack, ns))
+		}
+
+		if stacksMine {
+			if listed == 0 {
+				printer.Info(fmt.Sprintf("You don't own any named stacks (owner %s)", me))
+			}
+			return
+		}
+
+		// Stacks nobody has named yet start at a child of trunk
+		var unnamed []string
+		if trunk := stack.AllNodes[stack.MainBranch]; trunk != nil {
+			for _, child := range trunk.Children {
+				if stack.Named[child.Name] == nil {
+					unnamed = append(unnamed, child.Name)
+				}
+			}
+		}
+		sort.Strings(unnamed)
+
+		if len(unnamed) > 0 {
+			printer.Info("Unnamed stacks:")
+			for _, root := range unnamed {
+				count := len(stack.Members(&core.NamedStack{Root: root}))
+				printer.BulletPoint(fmt.Sprintf("%s (%d branch(es))", root, count))
+			}
+			printer.Info("Name one with 'stacksmith stacks describe <name> --branch <branch>'")
+		}
+
+		if listed == 0 && len(unnamed) == 0 {
+			printer.Info("No stacks found.")
+		}
+	},
+}
+
+var stacksShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "🔎 Show a named stack and its branches",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
+
+		stack, err := git.BuildBranchStack()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error analyzing branch structure: %s", err))
+			return
+		}
+
+		named := findNamedStack(printer, git, args[0])
+		if named == nil {
+			return
+		}
+
+		fmt.Print(printer.RenderNamedStack(stack, named))
+		printer.Divider()
+		printer.Info(fmt.Sprintf("Created %s", named.CreatedAt.Format("2006-01-02 15:04:05")))
+	},
+}
+
+var stacksDescribeCmd = &cobra.Command{
+	Use:   "describe <name>",
+	Short: "🏷️ Name a stack or update its details",
+	Long: `Name the stack containing the current branch (or --branch), or update the
+description, owner or issue of an existing named stack.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		defer finishOperation()
+
+		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
+		name := args[0]
+
+		stacks, err := git.LoadNamedStacks()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error reading stacks: %s", err))
+			return
+		}
+
+		var named *core.NamedStack
+		for _, ns := range stacks {
+			if ns.Name == name {
+				named = ns
+			}
+		}
+
+		created := named == nil
+		if created {
+			named = &core.NamedStack{Name: name, Owner: git.CurrentUser()}
+		}
+
+		// New stacks, or existing ones given --branch, are rooted at the bottom of that branch's stack
+		if created || cmd.Flags().Changed("branch") {
+			root, ok := stackRootOf(printer, git, stacksBranch)
+			if !ok {
+				return
+			}
+			named.Root = root
+		}
+
+		if cmd.Flags().Changed("description") {
+			named.Description = stacksDescription
+		}
+		if cmd.Flags().Changed("owner") {
+			named.Owner = stacksOwner
+		}
+		if cmd.Flags().Changed("issue") {
+			named.Issue = stacksIssue
+		}
+
+		if !recordOperation(printer, git, "stacks describe "+name) {
+			return
+		}
+
+		if err := git.SaveNamedStack(named); err != nil {
+			printer.Error(fmt.Sprintf("Error saving stack: %s", err))
+			return
+		}
+
+		if reportDryRun(printer, git) {
+			return
+		}
+
+		if created {
+			printer.Success(fmt.Sprintf("Named the stack rooted at %s '%s'", named.Root, name))
+		} else {
+			printer.Success(fmt.Sprintf("Updated stack '%s'", name))
+		}
+	},
+}
+
+// stackRootOf returns the bottom branch of the stack containing branch, or the current branch
+func stackRootOf(printer *render.Printer, git *core.GitExecutor, branch string) (string, bool) {
+	if branch == "" {
+		current, err := git.GetCurrentBranch()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error getting current branch: %s", err))
+			return "", false
+		}
+		branch = current
+	}
+
+	stack, err := git.BuildBranchStack()
+	if err != nil {
+		printer.Error(fmt.Sprintf("Error analyzing branch structure: %s", err))
+		return "", false
+	}
+
+	branches, err := stack.StackOf(branch)
+	if err != nil {
+		printer.HandleGitError(err)
+		return "", false
+	}
+	if len(branches) == 0 {
+		printer.Error(fmt.Sprintf("%s is not part of a stack", branch))
+		return "", false
+	}
+	return branches[0], true
+}
+
+// findNamedStack looks up a named stack, printing an error if there is none by that name
+func findNamedStack(printer *render.Printer, git *core.GitExecutor, name string) *core.NamedStack {
+	stacks, err := git.LoadNamedStacks()
+	if err != nil {
+		printer.Error(fmt.Sprintf("Error reading stacks: %s", err))
+		return nil
+	}
+
+	for _, named := range stacks {
+		if named.Name == name {
+			return named
+		}
+	}
+
+	printer.ErrorWithSolution(
+		fmt.Sprintf("No stack named '%s'", name),
+		"Run 'stacksmith stacks list' to see the named stacks",
+	)
+	return nil
+}
+
+// describeNamedStack summarises a named stack on one line
+func describeNamedStack(stack *core.BranchStack, named *core.NamedStack) string {
+	line := fmt.Sprintf("%s: %d branch(es) from %s", named.Name, len(stack.Members(named)), named.Root)
+	if stack.AllNodes[named.Root] == nil {
+		line = fmt.Sprintf("%s: root branch %s no longer exists", named.Name, named.Root)
+	}
+	if named.Issue != "" {
+		line += ", " + named.Issue
+	}
+	if named.Owner != "" {
+		line += ", owned by " + named.Owner
+	}
+	if named.Description != "" {
+		line += " — " + named.Description
+	}
+	return line
+}
+
+func init() {
+	stacksListCmd.Flags().BoolVar(&stacksMine, "mine", false, "Only list stacks you own")
+	stacksDescribeCmd.Flags().StringVarP(&stacksBranch, "branch", "b", "", "Branch in the stack to name (defaults to the current branch)")
+	stacksDescribeCmd.Flags().StringVarP(&stacksDescription, "description", "d", "", "What the stack is for")
+	stacksDescribeCmd.Flags().StringVar(&stacksOwner, "owner", "", "Who owns the stack (defaults to your git email)")
+	stacksDescribeCmd.Flags().StringVar(&stacksIssue, "issue", "", "Linked issue or ticket, e.g. PROJ-123")
+
+	stacksCmd.AddCommand(stacksListCmd)
+	stacksCmd.AddCommand(stacksShowCmd)
+	stacksCmd.AddCommand(stacksDescribeCmd)
+	rootCmd.AddCommand(stacksCmd)
+}
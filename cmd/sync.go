@@ -3,6 +3,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/mubbie/stacksmith/internal/core"
 	"github.com/mubbie/stacksmith/internal/render"
@@ -10,69 +11,137 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var syncCmd = &cobra.Command{
-	Use:   "sync [branch1] [branch2] ...",
-	Short: "🧽 Rebase multiple branches sequentially",
-	Long:  `Rebase and push a stack of branches in sequence.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		var branches []string
-		var success bool
+var syncJobs int
 
-		printer := render.NewPrinter("stacksmith")
+var syncCmd = &cobra.Command{
+	Use:   "sync [branch] | sync [branch1] [branch2] ...",
+	Short: "🧽 Restack a branch and all of its descendants",
+	Long: `Rebase and push every descendant of a branch (the current branch by default),
+following the parent/child relationships recorded for the stack.
 
-		if len(args) < 2 {
-			// Not enough arguments, launch the interactive prompt
-			branches, success = simplemenu.RunSyncPrompt()
-			if !success {
-				// Command was cancelled or failed, just return silently
-				return
-			}
-		} else {
-			branches = args
-		}
+Pass two or more branches to rebase exactly those branches in sequence instead.
 
-		git := core.NewGitExecutor("")
+If a rebase stops on a conflict, resolve it and run 'stacksmith sync --continue',
+or run 'stacksmith sync --abort' to put every branch back where it started.
 
-		printer.SyncStart()
+Use --jobs to restack independent subtrees concurrently in temporary worktrees.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		defer finishOperation()
 
-		for i := 1; i < len(branches); i++ {
-			child := branches[i]
-			parent := branches[i-1]
+		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
 
-			printer.RebaseStart(child, parent)
+		if handleResumeFlags(printer, git) {
+			return
+		}
 
-			err := git.CheckoutBranch(child)
+		// An explicit list of branches keeps the original sequential behaviour
+		if len(args) >= 2 {
+			steps, err := stepsFromBranchList(args)
 			if err != nil {
-				printer.Error(fmt.Sprintf("Error checking out %s: %s", child, err))
+				printer.Error(err.Error())
 				return
 			}
+			runSyncSteps(printer, git, steps)
+			return
+		}
 
-			err = git.FetchRemote()
+		root := ""
+		if len(args) == 1 {
+			root = args[0]
+		} else {
+			currentBranch, err := git.GetCurrentBranch()
 			if err != nil {
-				printer.Error(fmt.Sprintf("Error fetching remote: %s", err))
+				printer.Error(fmt.Sprintf("Error getting current branch: %s", err))
 				return
 			}
+			root = currentBranch
+		}
 
-			err = git.RebaseBranch(parent)
-			if err != nil {
-				printer.Error(fmt.Sprintf("Error rebasing %s onto %s: %s", child, parent, err))
-				return
-			}
+		stack, err := git.BuildBranchStack()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error analyzing branch structure: %s", err))
+			return
+		}
 
-			err = git.PushBranch()
-			if err != nil {
-				printer.Error(fmt.Sprintf("Error pushing %s: %s", child, err))
-				return
-			}
+		steps, err := stack.DescendantSteps(root)
+		if err != nil {
+			printer.HandleGitError(err)
+			return
+		}
 
-			printer.PushSuccess(child)
+		if len(steps) == 0 {
+			printer.Info(fmt.Sprintf("No branches are stacked on %s, nothing to sync.", root))
+			return
 		}
 
-		printer.Success("Stack sync complete!")
+		runSyncSteps(printer, git, steps)
 	},
 	Args: cobra.MaximumNArgs(100), // Allow multiple branches
 }
 
+// runInteractiveSync lets the user pick the branches to sync from the menu
+func runInteractiveSync() {
+	defer finishOperation()
+
+	branches, success := simplemenu.RunSyncPrompt()
+	if !success {
+		// Command was cancelled or failed, just return silently
+		return
+	}
+
+	printer := render.NewPrinter("stacksmith")
+	git := newGitExecutor()
+
+	steps, err := stepsFromBranchList(branches)
+	if err != nil {
+		printer.Error(err.Error())
+		return
+	}
+	runSyncSteps(printer, git, steps)
+}
+
+// stepsFromBranchList turns an ordered branch list into parent/child rebase steps. Each
+// branch may only appear once, since a branch can't be rebased onto two parents.
+func stepsFromBranchList(branches []string) ([]core.RestackStep, error) {
+	seen := make(map[string]bool)
+	for _, branch := range branches {
+		if seen[branch] {
+			return nil, fmt.Errorf("%s is listed more than once", branch)
+		}
+		seen[branch] = true
+	}
+
+	var steps []core.RestackStep
+	for i := 1; i < len(branches); i++ {
+		steps = append(steps, core.RestackStep{Branch: branches[i], Parent: branches[i-1]})
+	}
+	return steps, nil
+}
+
+// runSyncSteps rebases and pushes each branch onto its parent in order
+func runSyncSteps(printer *render.Printer, git *core.GitExecutor, steps []core.RestackStep) {
+	printer.SyncStart()
+
+	var branches []string
+	for _, step := range steps {
+		branches = append(branches, step.Branch)
+	}
+	if !noOperationInProgress(printer, git) || !recordOperation(printer, git, "sync "+strings.Join(branches, " ")) {
+		return
+	}
+
+	// A dry run only plans, so there is nothing to run in parallel
+	if syncJobs > 1 && !git.DryRun {
+		runParallelRestack(printer, git, steps, syncJobs)
+		return
+	}
+
+	startRestack(printer, git, "sync", steps)
+}
+
 func init() {
+	syncCmd.Flags().IntVarP(&syncJobs, "jobs", "j", 1, "Number of branches to restack at the same time")
+	addResumeFlags(syncCmd)
 	rootCmd.AddCommand(syncCmd)
 }
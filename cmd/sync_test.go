@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mubbie/stacksmith/internal/core"
+)
+
+func TestStepsFromBranchList(t *testing.T) {
+	tests := []struct {
+		name     string
+		branches []string
+		want     []core.RestackStep
+		wantErr  bool
+	}{
+		{
+			name:     "each branch onto the one before it",
+			branches: []string{"main", "a", "b"},
+			want:     []core.RestackStep{{Branch: "a", Parent: "main"}, {Branch: "b", Parent: "a"}},
+		},
+		{
+			name:     "a single branch has nothing to rebase",
+			branches: []string{"main"},
+		},
+		{
+			name:     "branch listed twice",
+			branches: []string{"main", "a", "b", "a"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stepsFromBranchList(tt.branches)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
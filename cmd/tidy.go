@@ -0,0 +1,139 @@
+// cmd/tidy.go
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mubbie/stacksmith/internal/core"
+	"github.com/mubbie/stacksmith/internal/render"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tidyAssumeYes    bool
+	tidyDeleteRemote bool
+)
+
+var tidyCmd = &cobra.Command{
+	Use:   "tidy",
+	Short: "🧹 Clean up branches that already landed in trunk",
+	Long: `Find branches whose changes are already in trunk, including squash and rebase merges,
+move their children onto trunk and delete them.
+
+Landed branches that are still on the remote are deleted there too with --delete-remote,
+otherwise tidy asks for each one. A branch whose children were moved is kept on the remote
+until their PRs are retargeted, so that they aren't closed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		defer finishOperation()
+
+		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
+
+		stack, err := git.BuildBranchStack()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error analyzing branch structure: %s", err))
+			return
+		}
+
+		merged := stack.MergedIntoTrunk()
+		if len(merged) == 0 {
+			printer.Info(fmt.Sprintf("No branches have landed in %s, nothing to tidy.", stack.MainBranch))
+			return
+		}
+
+		currentBranch, err := git.GetCurrentBranch()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error getting current branch: %s", err))
+			return
+		}
+
+		if !recordOperation(printer, git, "tidy") {
+			return
+		}
+
+		var reparented []string
+		for _, node := range merged {
+			question := fmt.Sprintf("%s has landed in %s. Delete it", node.Name, stack.MainBranch)
+			if len(node.Children) > 0 {
+				var children []string
+				for _, child := range node.Children {
+					children = append(children, child.Name)
+				}
+				question += fmt.Sprintf(" and move %s onto %s", strings.Join(children, ", "), stack.MainBranch)
+			}
+
+			if !tidyAssumeYes && !confirm(question+"?") {
+				continue
+			}
+
+			children, err := git.ReparentChildren(node.Name, stack.MainBranch)
+			if err != nil {
+				printer.Error(fmt.Sprintf("Error reparenting children of %s: %s", node.Name, err))
+				return
+			}
+			reparented = append(reparented, children...)
+
+			// Can't delete the branch we're standing on
+			if node.Name == currentBranch {
+				if err := git.CheckoutBranch(stack.MainBranch); err != nil {
+					printer.HandleGitError(err)
+					return
+				}
+				currentBranch = stack.MainBranch
+			}
+
+			if err := git.DeleteBranch(node.Name); err != nil {
+				printer.HandleGitError(err)
+				return
+			}
+			if err := git.ForgetBranch(node.Name); err != nil {
+				printer.Warning(fmt.Sprintf("Failed to remove %s from stack config: %s", node.Name, err))
+			}
+
+			if !git.DryRun {
+				printer.Success(fmt.Sprintf("Deleted %s", node.Name))
+			}
+
+			tidyRemoteBranch(printer, git, node.Name, len(children) > 0)
+		}
+
+		if reportDryRun(printer, git) {
+			return
+		}
+
+		for _, child := range reparented {
+			printer.RetargetReminder(child, stack.MainBranch)
+		}
+		if len(reparented) > 0 {
+			printer.Info(fmt.Sprintf("Run 'stacksmith sync %s' to restack the moved branches onto %s", stack.MainBranch, stack.MainBranch))
+		}
+	},
+	Args: cobra.NoArgs,
+}
+
+// tidyRemoteBranch deletes a tidied branch on the remote if it is there and the user wants it gone
+func tidyRemoteBranch(printer *render.Printer, git *core.GitExecutor, branch string, hasChildren bool) {
+	if !git.HasRemoteBranch(branch) {
+		return
+	}
+	if hasChildren {
+		printer.Info(fmt.Sprintf("Kept %s on the remote, delete it once the PRs built on it are retargeted", branch))
+		return
+	}
+	if !tidyDeleteRemote && (tidyAssumeYes || !confirmNo(fmt.Sprintf("Delete %s on the remote as well?", branch))) {
+		return
+	}
+
+	if err := git.DeleteRemoteBranch(branch); err != nil {
+		printer.Warning(fmt.Sprintf("Failed to delete %s on the remote: %s", branch, err))
+	} else if !git.DryRun {
+		printer.Success(fmt.Sprintf("Deleted %s on the remote", branch))
+	}
+}
+
+func init() {
+	tidyCmd.Flags().BoolVarP(&tidyAssumeYes, "yes", "y", false, "Tidy every landed branch without asking")
+	tidyCmd.Flags().BoolVar(&tidyDeleteRemote, "delete-remote", false, "Also delete landed branches on the remote")
+	rootCmd.AddCommand(tidyCmd)
+}
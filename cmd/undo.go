@@ -0,0 +1,92 @@
+// cmd/undo.go
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mubbie/stacksmith/internal/render"
+	"github.com/spf13/cobra"
+)
+
+var undoList bool
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [operation-id]",
+	Short: "⏪ Undo the last stacksmith operation",
+	Long: `Restore every local branch and the recorded stack to how they were before the last
+stacksmith operation, or before the operation with the given id.
+
+Use --list to see the operations that can be undone.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		printer := render.NewPrinter("stacksmith")
+		git := newGitExecutor()
+
+		snapshots, err := git.ListSnapshots()
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error reading operation log: %s", err))
+			return
+		}
+
+		if len(snapshots) == 0 {
+			printer.Info("No operations to undo.")
+			return
+		}
+
+		if undoList {
+			printer.OperationLog(snapshots)
+			return
+		}
+
+		if !noOperationInProgress(printer, git) {
+			return
+		}
+
+		id := snapshots[len(snapshots)-1].ID
+		if len(args) == 1 {
+			id = args[0]
+		}
+
+		// Work done after the operation finished is only thrown away if the user says so
+		changed, err := git.ChangedSinceOperation(id)
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error undoing operation: %s", err))
+			return
+		}
+		includeChanged := false
+		if len(changed) > 0 {
+			printer.Warning(fmt.Sprintf("%s changed after the operation finished", strings.Join(changed, ", ")))
+			includeChanged = confirmNo("Reset or delete them as well, losing those changes?")
+		}
+
+		snapshot, result, err := git.UndoOperation(id, includeChanged)
+		if err != nil {
+			printer.Error(fmt.Sprintf("Error undoing operation: %s", err))
+			return
+		}
+
+		if reportDryRun(printer, git) {
+			return
+		}
+
+		printer.Success(fmt.Sprintf("Undid '%s' from %s", snapshot.Command, snapshot.CreatedAt.Format("2006-01-02 15:04:05")))
+		if len(result.Restored) > 0 {
+			printer.Info("Restored: " + strings.Join(result.Restored, ", "))
+		}
+		if len(result.Deleted) > 0 {
+			printer.Info("Deleted: " + strings.Join(result.Deleted, ", "))
+		}
+		if len(result.Skipped) > 0 {
+			printer.Info("Left as they are: " + strings.Join(result.Skipped, ", "))
+		}
+		for _, branch := range result.RemoteChanged {
+			printer.Warning(fmt.Sprintf("The remote copy of %s has changed since; run 'stacksmith push' on it to update the remote", branch))
+		}
+	},
+	Args: cobra.MaximumNArgs(1),
+}
+
+func init() {
+	undoCmd.Flags().BoolVar(&undoList, "list", false, "List the operations that can be undone")
+	rootCmd.AddCommand(undoCmd)
+}
@@ -1 +1,81 @@
+// config/config.go
 package config
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/mubbie/stacksmith/internal/core"
+)
+
+// Config holds user preferences, read from `stacksmith.*` keys in git config
+//
+//	git config stacksmith.autoStash true
+//	git config stacksmith.store git-config
+type Config struct {
+	AutoStash bool   // Stash uncommitted changes around stack operations without asking
+	Store     string // Stack store backend: file, git-config or memory
+}
+
+// Default returns the configuration used when nothing is set
+func Default() *Config {
+	return &Config{Store: core.StoreFile}
+}
+
+// Load reads the configuration from git config, falling back to defaults for unset keys
+func Load(git *core.GitExecutor) (*Config, error) {
+	cfg := Default()
+
+	values, err := readGitConfig(git)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := values["stacksmith.autostash"]; ok {
+		cfg.AutoStash = parseBool(value)
+	}
+	if value, ok := values["stacksmith.store"]; ok {
+		cfg.Store = strings.ToLower(strings.TrimSpace(value))
+	}
+
+	return cfg, nil
+}
+
+// readGitConfig returns every stacksmith key in git config, with lowercased names
+func readGitConfig(git *core.GitExecutor) (map[string]string, error) {
+	values := make(map[string]string)
+
+	output, err := git.Execute("config", "--get-regexp", `^stacksmith\.`)
+	if err != nil {
+		// git config exits with 1 when nothing matches, anything else is a real failure
+		var gitErr *core.GitError
+		if errors.As(err, &gitErr) && gitErr.ExitCode() == 1 {
+			return values, nil
+		}
+		return nil, err
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) == 0 || parts[0] == "" {
+			continue
+		}
+
+		value := ""
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+		values[strings.ToLower(parts[0])] = value
+	}
+
+	return values, nil
+}
+
+// parseBool interprets a git config boolean
+func parseBool(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "on", "1", "":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,322 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/bits"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxGitJobs bounds how many git processes stack analysis runs at once
+var maxGitJobs = func() int {
+	if jobs := runtime.NumCPU(); jobs < 8 {
+		return jobs + 1
+	}
+	return 8
+}()
+
+// aheadBehind counts the commits a branch has that its base doesn't (Ahead), and the reverse (Behind)
+type aheadBehind struct {
+	Ahead  int
+	Behind int
+}
+
+// commitCounter answers ahead/behind questions for many pairs of local branches
+type commitCounter interface {
+	// counts returns every local branch's commits ahead of and behind base
+	counts(base string) (map[string]aheadBehind, error)
+}
+
+// runBounded: Call fn for 0..n-1 with at most jobs calls running at a time
+func runBounded(n, jobs int, fn func(i int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, jobs)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// refCounter counts with for-each-ref's ahead-behind atom (git 2.41+), one call per base
+type refCounter struct {
+	g       *GitExecutor
+	mu      sync.Mutex
+	results map[string]map[string]aheadBehind
+	errs    map[string]error
+}
+
+// prefetch: Count against every base concurrently, so later counts calls don't run git.
+// Errors are kept and returned by those calls.
+func (r *refCounter) prefetch(bases []string) {
+	runBounded(len(bases), maxGitJobs, func(i int) {
+		r.counts(bases[i])
+	})
+}
+
+func (r *refCounter) counts(base string) (map[string]aheadBehind, error) {
+	r.mu.Lock()
+	result, cached := r.results[base]
+	err := r.errs[base]
+	r.mu.Unlock()
+	if cached || err != nil {
+		return result, err
+	}
+
+	result, err = r.count(base)
+
+	r.mu.Lock()
+	if err != nil {
+		r.errs[base] = err
+	} else {
+		r.results[base] = result
+	}
+	r.mu.Unlock()
+	return result, err
+}
+
+// count: Run for-each-ref against base and parse what it prints
+func (r *refCounter) count(base string) (map[string]aheadBehind, error) {
+	output, err := r.g.Execute("for-each-ref", "--format=%(refname:short) %(ahead-behind:"+base+")", "refs/heads/")
+	if err != nil {
+		return nil, err
+	}
+	return parseAheadBehind(output)
+}
+
+// parseAheadBehind: Read the "<branch> <ahead> <behind>" lines for-each-ref prints
+func parseAheadBehind(output string) (map[string]aheadBehind, error) {
+	result := make(map[string]aheadBehind)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected ahead-behind output %q", line)
+		}
+		ahead, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected ahead-behind output %q", line)
+		}
+		behind, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected ahead-behind output %q", line)
+		}
+		result[fields[0]] = aheadBehind{Ahead: ahead, Behind: behind}
+	}
+	return result, nil
+}
+
+// commitGraph is the history of every local branch since their common ancestor, read with a
+// single rev-list walk. Commits below the common ancestor are in every branch, so they never
+// change a count and are left out.
+type commitGraph struct {
+	branches []string
+	index    map[string]int // Branch name to position in branches and reach
+	reach    [][]uint64     // For each branch, a bitset of the walked commits it contains
+	sizes    []int          // For each branch, how many walked commits it contains
+}
+
+// loadCommitGraph: Walk the history of the given branches, mapped to their tip commits
+func (g *GitExecutor) loadCommitGraph(branches map[string]string) (*commitGraph, error) {
+	graph := &commitGraph{index: make(map[string]int)}
+	var tips []string
+	for name, sha := range branches {
+		graph.index[name] = len(graph.branches)
+		graph.branches = append(graph.branches, name)
+		tips = append(tips, sha)
+	}
+	if len(tips) == 0 {
+		return graph, nil
+	}
+
+	args := append([]string{"rev-list", "--topo-order", "--parents"}, tips...)
+	if len(tips) > 1 {
+		// Unrelated histories have no common ancestor, then everything is walked
+		if base, err := g.Execute(append([]string{"merge-base", "--octopus"}, tips...)...); err == nil {
+			args = append(args, "--not", strings.TrimSpace(base))
+		}
+	}
+	output, err := g.Execute(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Children come before their parents, so one pass pushes each branch down its history
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	position := make(map[string]int, len(lines))
+	parents := make([][]string, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		position[fields[0]] = len(parents)
+		parents = append(parents, fields[1:])
+	}
+
+	words := (len(graph.branches) + 63) / 64
+	containedIn := make([][]uint64, len(parents))
+	for i := range containedIn {
+		containedIn[i] = make([]uint64, words)
+	}
+	for name, sha := range branches {
+		if i, walked := position[sha]; walked {
+			b := graph.index[name]
+			containedIn[i][b/64] |= 1 << (b % 64)
+		}
+	}
+	for i, commitParents := range parents {
+		for _, parent := range commitParents {
+			if p, walked := position[parent]; walked {
+				for w := range containedIn[p] {
+					containedIn[p][w] |= containedIn[i][w]
+				}
+			}
+		}
+	}
+
+	// Turn it around into the commits each branch contains, so pairs compare with a few ANDs
+	commitWords := (len(parents) + 63) / 64
+	graph.reach = make([][]uint64, len(graph.branches))
+	graph.sizes = make([]int, len(graph.branches))
+	for b := range graph.reach {
+		graph.reach[b] = make([]uint64, commitWords)
+	}
+	for i, set := range containedIn {
+		for w, word := range set {
+			for word != 0 {
+				b := w*64 + bits.TrailingZeros64(word)
+				graph.reach[b][i/64] |= 1 << (i % 64)
+				graph.sizes[b]++
+				word &= word - 1
+			}
+		}
+	}
+
+	return graph, nil
+}
+
+func (c *commitGraph) counts(base string) (map[string]aheadBehind, error) {
+	result := make(map[string]aheadBehind)
+	b, exists := c.index[base]
+	if !exists {
+		return nil, &BranchNotFoundError{BranchName: base}
+	}
+
+	for name, i := range c.index {
+		shared := 0
+		for w, word := range c.reach[i] {
+			shared += bits.OnesCount64(word & c.reach[b][w])
+		}
+		result[name] = aheadBehind{Ahead: c.sizes[i] - shared, Behind: c.sizes[b] - shared}
+	}
+	return result, nil
+}
+
+// newCommitCounter: Return the fastest counter this git supports for the given local branches,
+// prefetching counts against bases
+func (g *GitExecutor) newCommitCounter(branches map[string]string, bases []string) (commitCounter, error) {
+	if g.SupportsVersion(2, 41) {
+		counter := &refCounter{g: g, results: make(map[string]map[string]aheadBehind), errs: make(map[string]error)}
+		counter.prefetch(bases)
+		return counter, nil
+	}
+	return g.loadCommitGraph(branches)
+}
+
+// catFile is a long-lived `git cat-file --batch` process, for reading many objects
+// without starting git for each one
+type catFile struct {
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// startCatFile: Start a cat-file process, close it when done
+func (g *GitExecutor) startCatFile() (*catFile, error) {
+	cmd := exec.Command("git", "cat-file", "--batch")
+	if g.WorkDir != "" {
+		cmd.Dir = g.WorkDir
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &catFile{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}, nil
+}
+
+// read: Return the type and content of an object
+func (c *catFile) read(rev string) (string, []byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := fmt.Fprintln(c.stdin, rev); err != nil {
+		return "", nil, err
+	}
+
+	// Header is "<sha> <type> <size>", or "<rev> missing"
+	header, err := c.stdout.ReadString('\n')
+	if err != nil {
+		return "", nil, err
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return "", nil, fmt.Errorf("object %s not found", rev)
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Content is followed by a newline
+	content := make([]byte, size+1)
+	if _, err := io.ReadFull(c.stdout, content); err != nil {
+		return "", nil, err
+	}
+	return fields[1], content[:size], nil
+}
+
+// tree: Return the tree SHA of a commit
+func (c *catFile) tree(rev string) (string, error) {
+	kind, content, err := c.read(rev)
+	if err != nil {
+		return "", err
+	}
+	if kind != "commit" {
+		return "", fmt.Errorf("%s is a %s, not a commit", rev, kind)
+	}
+
+	// The tree is always the first header line
+	tree, _, _ := strings.Cut(string(content), "\n")
+	return strings.TrimPrefix(tree, "tree "), nil
+}
+
+// Close: Stop the cat-file process
+func (c *catFile) Close() error {
+	c.stdin.Close()
+	return c.cmd.Wait()
+}
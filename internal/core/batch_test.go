@@ -0,0 +1,192 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var counterFixtures = []struct {
+	name     string
+	commands [][]string
+}{
+	{
+		name: "linear stack",
+		commands: [][]string{
+			commit("m1"), commit("m2"),
+			{"checkout", "-q", "-b", "a"}, commit("a1"), commit("a2"),
+			{"checkout", "-q", "-b", "b"}, commit("b1"),
+			{"checkout", "-q", "main"}, commit("m3"),
+		},
+	},
+	{
+		name: "merges both ways",
+		commands: [][]string{
+			commit("m1"),
+			{"checkout", "-q", "-b", "a"}, commit("a1"), commit("a2"),
+			{"checkout", "-q", "-b", "b", "a~1"}, commit("b1"),
+			{"checkout", "-q", "main"}, commit("m2"),
+			{"checkout", "-q", "b"}, merge("main"), commit("b2"),
+			{"checkout", "-q", "-b", "c", "main"}, commit("c1"), merge("a"),
+			{"checkout", "-q", "main"}, merge("c"), commit("m3"),
+			{"checkout", "-q", "-b", "d", "b"}, merge("c"),
+		},
+	},
+	{
+		name: "unrelated history",
+		commands: [][]string{
+			commit("m1"),
+			{"checkout", "-q", "-b", "a"}, commit("a1"),
+			{"checkout", "-q", "--orphan", "lone"}, commit("l1"), commit("l2"),
+			{"checkout", "-q", "-b", "lone-child"}, commit("l3"),
+		},
+	},
+}
+
+func TestCommitCountersMatchRevList(t *testing.T) {
+	counters := []struct {
+		name  string
+		start func(g *GitExecutor, branches map[string]string) (commitCounter, error)
+	}{
+		{
+			name: "commit graph",
+			start: func(g *GitExecutor, branches map[string]string) (commitCounter, error) {
+				return g.loadCommitGraph(branches)
+			},
+		},
+		{
+			name: "for-each-ref ahead-behind",
+			start: func(g *GitExecutor, branches map[string]string) (commitCounter, error) {
+				if !g.SupportsVersion(2, 41) {
+					return nil, nil
+				}
+				return &refCounter{g: g, results: make(map[string]map[string]aheadBehind), errs: make(map[string]error)}, nil
+			},
+		},
+	}
+
+	for _, fixture := range counterFixtures {
+		for _, counter := range counters {
+			t.Run(fixture.name+"/"+counter.name, func(t *testing.T) {
+				g := fixtureRepo(t, fixture.commands)
+				branches, err := g.getBranchesWithCommits()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				c, err := counter.start(g, branches)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if c == nil {
+					t.Skip("git is too old for ahead-behind")
+				}
+
+				for base := range branches {
+					counts, err := c.counts(base)
+					if err != nil {
+						t.Fatalf("counts(%s): %v", base, err)
+					}
+
+					for branch := range branches {
+						want, related := revListCount(t, g, base, branch)
+						got, exists := counts[branch]
+						if !related {
+							// Unrelated pairs share nothing, so a counter may leave them out
+							if exists && got != want {
+								t.Errorf("%s against %s: got %+v, want %+v", branch, base, got, want)
+							}
+							continue
+						}
+						if !exists || got != want {
+							t.Errorf("%s against %s: got %+v (present %v), want %+v", branch, base, got, exists, want)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRefCounterReturnsErrors(t *testing.T) {
+	g := fixtureRepo(t, [][]string{commit("m1")})
+	if !g.SupportsVersion(2, 41) {
+		t.Skip("git is too old for ahead-behind")
+	}
+
+	counter := &refCounter{g: g, results: make(map[string]map[string]aheadBehind), errs: make(map[string]error)}
+	if _, err := counter.counts("no-such-branch"); err == nil {
+		t.Fatal("expected an error counting against a missing base")
+	}
+}
+
+func TestParseAheadBehind(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    map[string]aheadBehind
+		wantErr bool
+	}{
+		{
+			name:   "branches",
+			output: "main 0 0\nfeature/a 3 1\n",
+			want:   map[string]aheadBehind{"main": {}, "feature/a": {Ahead: 3, Behind: 1}},
+		},
+		{
+			name:   "no branches",
+			output: "",
+			want:   map[string]aheadBehind{},
+		},
+		{
+			name:    "ahead-behind not understood",
+			output:  "main \nfeature 1 2\n",
+			wantErr: true,
+		},
+		{
+			name:    "not a number",
+			output:  "main x 0\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAheadBehind(tt.output)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for branch, count := range tt.want {
+				if got[branch] != count {
+					t.Errorf("%s: got %+v, want %+v", branch, got[branch], count)
+				}
+			}
+		})
+	}
+}
+
+// revListCount: Count what branch has that base doesn't and the reverse with rev-list,
+// reporting whether the two share any history
+func revListCount(t *testing.T, g *GitExecutor, base, branch string) (aheadBehind, bool) {
+	t.Helper()
+	_, err := g.Execute("merge-base", base, branch)
+	related := err == nil
+
+	output, err := g.Execute("rev-list", "--left-right", "--count", fmt.Sprintf("%s...%s", base, branch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Fields(output)
+	behind, _ := strconv.Atoi(fields[0])
+	ahead, _ := strconv.Atoi(fields[1])
+	return aheadBehind{Ahead: ahead, Behind: behind}, related
+}
@@ -1 +1,202 @@
 package core
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// cacheVersion changes whenever what is cached, or how it is computed, changes
+const cacheVersion = 1
+
+// cacheFile is the cached stack state as stored on disk. Every entry is keyed by the commits
+// it was computed from, so entries for refs that have moved are never looked up again.
+type cacheFile struct {
+	Version  int                     `yaml:"version"`
+	Counts   map[string]aheadBehind  `yaml:"counts"`   // Keyed by "<branch sha> <parent sha>"
+	Landed   map[string]bool         `yaml:"landed"`   // Keyed by "<branch sha> <trunk sha> <fork point>"
+	Snapshot string                  `yaml:"snapshot"` // Branches and relationships the guesses were made from
+	Guesses  map[string]*ParentGuess `yaml:"guesses"`
+}
+
+// stackCache looks entries up in the cache as loaded, and collects the entries used this
+// time, so that only those are saved and stale entries are pruned
+type stackCache struct {
+	loaded *cacheFile
+	next   *cacheFile
+}
+
+func newCacheFile() *cacheFile {
+	return &cacheFile{
+		Version: cacheVersion,
+		Counts:  make(map[string]aheadBehind),
+		Landed:  make(map[string]bool),
+		Guesses: make(map[string]*ParentGuess),
+	}
+}
+
+// cachePath: Return the location of the stack cache, shared by every worktree
+func (g *GitExecutor) cachePath() (string, error) {
+	stacksmithDir, err := g.stacksmithDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stacksmithDir, "cache", "stack.yml"), nil
+}
+
+// loadCache: Return the stack cache, empty if caching is off or the cache can't be read
+func (g *GitExecutor) loadCache() *stackCache {
+	cache := &stackCache{loaded: newCacheFile(), next: newCacheFile()}
+	if g.NoCache {
+		return cache
+	}
+
+	path, err := g.cachePath()
+	if err != nil {
+		return cache
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+
+	loaded := newCacheFile()
+	if err := yaml.Unmarshal(data, loaded); err != nil || loaded.Version != cacheVersion {
+		return cache // Rebuilt from scratch
+	}
+	if loaded.Counts == nil {
+		loaded.Counts = make(map[string]aheadBehind)
+	}
+	if loaded.Landed == nil {
+		loaded.Landed = make(map[string]bool)
+	}
+	cache.loaded = loaded
+	return cache
+}
+
+// saveCache: Write the entries used this time, if anything changed. The cache only saves
+// work, so failing to write it is not an error.
+func (g *GitExecutor) saveCache(cache *stackCache) {
+	if g.NoCache || g.DryRun || reflect.DeepEqual(cache.loaded, cache.next) {
+		return
+	}
+
+	path, err := g.cachePath()
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	data, err := yaml.Marshal(cache.next)
+	if err != nil {
+		return
+	}
+
+	// Replace the file in one rename, so a concurrent reader never sees half of it
+	tmp, err := os.CreateTemp(filepath.Dir(path), "stack.yml.*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// ClearCache: Remove the cached stack state
+func (g *GitExecutor) ClearCache() error {
+	path, err := g.cachePath()
+	if err != nil {
+		return err
+	}
+	if g.DryRun {
+		g.recordPlannedWrite("Remove " + filepath.Dir(path))
+		return nil
+	}
+	return os.RemoveAll(filepath.Dir(path))
+}
+
+// count: Look up the commits a branch is ahead of and behind its parent, by their tips
+func (c *stackCache) count(branchSHA, parentSHA string) (aheadBehind, bool) {
+	key := branchSHA + " " + parentSHA
+	count, cached := c.loaded.Counts[key]
+	if cached {
+		c.next.Counts[key] = count
+	}
+	return count, cached
+}
+
+// setCount: Remember the commits a branch is ahead of and behind its parent
+func (c *stackCache) setCount(branchSHA, parentSHA string, count aheadBehind) {
+	c.next.Counts[branchSHA+" "+parentSHA] = count
+}
+
+// landed: Look up whether a branch tip has landed in a trunk tip
+func (c *stackCache) landed(branchSHA, trunkSHA, forkPoint string) (bool, bool) {
+	key := strings.Join([]string{branchSHA, trunkSHA, forkPoint}, " ")
+	landed, cached := c.loaded.Landed[key]
+	if cached {
+		c.next.Landed[key] = landed
+	}
+	return landed, cached
+}
+
+// setLanded: Remember whether a branch tip has landed in a trunk tip
+func (c *stackCache) setLanded(branchSHA, trunkSHA, forkPoint string, landed bool) {
+	c.next.Landed[strings.Join([]string{branchSHA, trunkSHA, forkPoint}, " ")] = landed
+}
+
+// guesses: Look up the parent guesses made from a snapshot, see guessSnapshot
+func (c *stackCache) guesses(snapshot string) (map[string]*ParentGuess, bool) {
+	if c.loaded.Snapshot != snapshot || c.loaded.Guesses == nil {
+		return nil, false
+	}
+	c.setGuesses(snapshot, c.loaded.Guesses)
+	return c.loaded.Guesses, true
+}
+
+// setGuesses: Remember the parent guesses made from a snapshot, keeping only the candidates
+// needed to explain each guess
+func (c *stackCache) setGuesses(snapshot string, guesses map[string]*ParentGuess) {
+	c.next.Snapshot = snapshot
+	c.next.Guesses = make(map[string]*ParentGuess)
+	for name, guess := range guesses {
+		kept := *guess
+		if len(kept.Candidates) > 2 {
+			kept.Candidates = kept.Candidates[:2]
+		}
+		c.next.Guesses[name] = &kept
+	}
+}
+
+// guessSnapshot: Hash everything parent guesses depend on, every branch tip, the trunk and
+// which branches are tracked, so guesses are made again as soon as any of it changes
+func guessSnapshot(branches map[string]string, trunk string, tracked map[string]bool, untracked []string) string {
+	var lines []string
+	for name, sha := range branches {
+		lines = append(lines, "branch "+name+" "+sha)
+	}
+	for name := range tracked {
+		lines = append(lines, "tracked "+name)
+	}
+	for _, name := range untracked {
+		lines = append(lines, "untracked "+name)
+	}
+	sort.Strings(lines)
+
+	hash := sha1.Sum([]byte("trunk " + trunk + "\n" + strings.Join(lines, "\n")))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,303 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// IssueKind identifies a problem found in the recorded stack
+type IssueKind string
+
+const (
+	IssueSelfParent     IssueKind = "self-parent"     // Branch is recorded as its own parent
+	IssueCycle          IssueKind = "cycle"           // Parents loop back on themselves
+	IssueDanglingParent IssueKind = "dangling-parent" // Parent branch doesn't exist locally
+	IssueNotAncestor    IssueKind = "not-ancestor"    // Branch no longer contains any of its parent's commits
+	IssueStaleBranch    IssueKind = "stale-branch"    // Relationship for a branch that no longer exists anywhere
+	IssueTrunkMissing   IssueKind = "trunk-missing"   // Recorded trunk doesn't exist locally
+	IssueTrunkMismatch  IssueKind = "trunk-mismatch"  // Recorded trunk differs from origin's default branch
+	IssueUntracked      IssueKind = "untracked"       // Branch has no recorded parent, but one can be guessed
+)
+
+// StackIssue is a problem found by Diagnose, together with the repair that fixes it
+type StackIssue struct {
+	Kind    IssueKind
+	Branch  string // Branch the issue is about, or the trunk for trunk issues
+	Parent  string // Recorded parent, when relevant
+	Message string // What is wrong
+	Fix     string // What Repair will do
+
+	newParent string // Parent to record, for reparenting repairs
+	newTrunk  string // Trunk to record, for trunk repairs
+}
+
+// Diagnose: Check the recorded stack for cycles, self-parenting, dangling parents, branches
+// that no longer build on their parent, stale entries, trunk mismatches and branches whose
+// parent was guessed with too little confidence to record
+func (g *GitExecutor) Diagnose() ([]StackIssue, error) {
+	config, err := g.LoadStackConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := g.getBranchesWithCommits()
+	if err != nil {
+		return nil, err
+	}
+	remote, err := g.remoteBranchSHAs()
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []StackIssue
+
+	// Trunk problems come first, every other repair depends on the trunk
+	trunk := config.Metadata.MainBranch
+	defaultBranch := g.remoteDefaultBranch()
+	if local[trunk] == "" {
+		newTrunk := defaultBranch
+		if local[newTrunk] == "" {
+			newTrunk = ""
+			for _, name := range []string{"main", "master"} {
+				if local[name] != "" {
+					newTrunk = name
+					break
+				}
+			}
+		}
+		issue := StackIssue{Kind: IssueTrunkMissing, Branch: trunk, Message: fmt.Sprintf("Trunk %s doesn't exist locally", trunk)}
+		if trunk == "" {
+			issue.Message = "No trunk is recorded"
+		}
+		if newTrunk != "" {
+			issue.Fix = fmt.Sprintf("Use %s as trunk", newTrunk)
+			issue.newTrunk = newTrunk
+		}
+		issues = append(issues, issue)
+		trunk = newTrunk
+	} else if defaultBranch != "" && defaultBranch != trunk && local[defaultBranch] != "" {
+		issues = append(issues, StackIssue{
+			Kind:     IssueTrunkMismatch,
+			Branch:   trunk,
+			Message:  fmt.Sprintf("Trunk is recorded as %s but origin's default branch is %s", trunk, defaultBranch),
+			Fix:      fmt.Sprintf("Use %s as trunk", defaultBranch),
+			newTrunk: defaultBranch,
+		})
+		trunk = defaultBranch
+	}
+
+	var children []string
+	for child := range config.Relationships {
+		children = append(children, child)
+	}
+	sort.Strings(children)
+
+	// Find every cycle once, reparenting its first branch breaks it
+	inCycle := make(map[string]bool)
+	for _, child := range children {
+		cycle := findCycle(child, config.Relationships)
+		if len(cycle) < 2 || inCycle[cycle[0]] {
+			continue
+		}
+		for _, branch := range cycle {
+			inCycle[branch] = true
+		}
+		issues = append(issues, StackIssue{
+			Kind:      IssueCycle,
+			Branch:    cycle[0],
+			Parent:    config.Relationships[cycle[0]],
+			Message:   fmt.Sprintf("Parents form a cycle: %s → %s", strings.Join(cycle, " → "), cycle[0]),
+			Fix:       fmt.Sprintf("Move %s onto %s", cycle[0], trunk),
+			newParent: trunk,
+		})
+	}
+
+	for _, child := range children {
+		parent := config.Relationships[child]
+
+		switch {
+		case local[child] == "" && remote["origin/"+child] == "":
+			issues = append(issues, StackIssue{
+				Kind:    IssueStaleBranch,
+				Branch:  child,
+				Parent:  parent,
+				Message: fmt.Sprintf("%s no longer exists but is still recorded", child),
+				Fix:     fmt.Sprintf("Forget %s", child),
+			})
+		case local[child] == "":
+			// Only on origin, kept for when it is checked out
+		case child == parent:
+			issues = append(issues, StackIssue{
+				Kind:      IssueSelfParent,
+				Branch:    child,
+				Parent:    parent,
+				Message:   fmt.Sprintf("%s is recorded as its own parent", child),
+				Fix:       fmt.Sprintf("Move %s onto %s", child, trunk),
+				newParent: trunk,
+			})
+		case inCycle[child]:
+			// Reported with its cycle
+		case local[parent] == "":
+			newParent := nearestLocalAncestor(parent, config.Relationships, local, trunk)
+			issues = append(issues, StackIssue{
+				Kind:      IssueDanglingParent,
+				Branch:    child,
+				Parent:    parent,
+				Message:   fmt.Sprintf("%s's parent %s doesn't exist locally", child, parent),
+				Fix:       fmt.Sprintf("Move %s onto %s", child, newParent),
+				newParent: newParent,
+			})
+		case parent != trunk && trunk != "" && !g.buildsOn(child, parent, trunk, config.ForkPoints[child]):
+			issues = append(issues, StackIssue{
+				Kind:      IssueNotAncestor,
+				Branch:    child,
+				Parent:    parent,
+				Message:   fmt.Sprintf("%s no longer contains any of %s's commits", child, parent),
+				Fix:       fmt.Sprintf("Move %s onto %s", child, trunk),
+				newParent: trunk,
+			})
+		}
+	}
+
+	// Guesses build on the recorded stack, so they are checked last
+	tracked := make(map[string]bool)
+	for child, parent := range config.Relationships {
+		tracked[child] = true
+		tracked[parent] = true
+	}
+
+	var untracked []string
+	for branch := range local {
+		if branch != trunk && config.Relationships[branch] == "" {
+			untracked = append(untracked, branch)
+		}
+	}
+	sort.Strings(untracked)
+
+	guesses, err := g.InferParents(untracked, local, trunk, tracked)
+	if err != nil {
+		return nil, err
+	}
+
+	proposed := make(map[string]string)
+	for _, branch := range untracked {
+		guess := guesses[branch]
+		if guess == nil {
+			continue
+		}
+		if proposed[guess.Parent] == branch {
+			continue // Siblings guessing each other, recording both would make a cycle
+		}
+		proposed[branch] = guess.Parent
+
+		issues = append(issues, StackIssue{
+			Kind:      IssueUntracked,
+			Branch:    branch,
+			Message:   fmt.Sprintf("%s has no recorded parent, it may build on %s (%s confidence: %s)", branch, guess.Parent, guess.Confidence, guess.Reason()),
+			Fix:       fmt.Sprintf("Record %s as %s's parent", guess.Parent, branch),
+			newParent: guess.Parent,
+		})
+	}
+
+	return issues, nil
+}
+
+// Repair: Apply the fix for an issue found by Diagnose
+func (g *GitExecutor) Repair(issue StackIssue) error {
+	return g.UpdateStackConfig(func(config *StackConfig) error {
+		return applyRepair(config, issue)
+	})
+}
+
+// applyRepair: Change the config as the fix for issue describes
+func applyRepair(config *StackConfig, issue StackIssue) error {
+	switch issue.Kind {
+	case IssueTrunkMissing, IssueTrunkMismatch:
+		if issue.newTrunk == "" {
+			return fmt.Errorf("no branch to use as trunk")
+		}
+		config.Metadata.MainBranch = issue.newTrunk
+	case IssueStaleBranch:
+		delete(config.Relationships, issue.Branch)
+		delete(config.ForkPoints, issue.Branch)
+	case IssueNotAncestor:
+		// The fork point belonged to a parent the branch no longer builds on
+		config.Relationships[issue.Branch] = issue.newParent
+		delete(config.ForkPoints, issue.Branch)
+	default:
+		if issue.newParent == "" {
+			return fmt.Errorf("no branch to move %s onto", issue.Branch)
+		}
+		// Keep the fork point so a restack only moves the branch's own commits
+		config.Relationships[issue.Branch] = issue.newParent
+	}
+	return nil
+}
+
+// findCycle: Follow parents from branch, returning the branches of the cycle it runs into,
+// starting from the alphabetically first one, or nil if it reaches a branch without a parent
+func findCycle(branch string, relationships map[string]string) []string {
+	seen := make(map[string]int)
+	var path []string
+	for current := branch; current != ""; current = relationships[current] {
+		if start, exists := seen[current]; exists {
+			cycle := path[start:]
+
+			// Rotate so every branch of the cycle reports it the same way
+			first := 0
+			for i, name := range cycle {
+				if name < cycle[first] {
+					first = i
+				}
+			}
+			return append(append([]string{}, cycle[first:]...), cycle[:first]...)
+		}
+		seen[current] = len(path)
+		path = append(path, current)
+	}
+	return nil
+}
+
+// nearestLocalAncestor: Walk up the recorded parents of branch to the first one that exists locally
+func nearestLocalAncestor(branch string, relationships, local map[string]string, trunk string) string {
+	visited := make(map[string]bool)
+	for current := branch; current != "" && !visited[current]; current = relationships[current] {
+		if local[current] != "" {
+			return current
+		}
+		visited[current] = true
+	}
+	return trunk
+}
+
+// buildsOn: Check if branch still contains parent's tip, the commit it was last based on,
+// or at least some of parent's commits that aren't in trunk
+func (g *GitExecutor) buildsOn(branch, parent, trunk, forkPoint string) bool {
+	if g.IsAncestor(parent, branch) {
+		return true
+	}
+	if forkPoint != "" && g.IsAncestor(forkPoint, branch) && !g.IsAncestor(forkPoint, trunk) {
+		return true
+	}
+
+	// A parent with nothing beyond trunk has nothing to build on
+	if g.IsAncestor(parent, trunk) {
+		return true
+	}
+
+	output, err := g.Execute("merge-base", branch, parent)
+	if err != nil {
+		return false
+	}
+	return !g.IsAncestor(strings.TrimSpace(output), trunk)
+}
+
+// remoteDefaultBranch: Return the branch origin/HEAD points at, or "" if it isn't known
+func (g *GitExecutor) remoteDefaultBranch() string {
+	output, err := g.Execute("symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimSpace(output), "origin/")
+}
@@ -0,0 +1,218 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindCycle(t *testing.T) {
+	tests := []struct {
+		name          string
+		branch        string
+		relationships map[string]string
+		want          []string
+	}{
+		{
+			name:          "reaches trunk",
+			branch:        "b",
+			relationships: map[string]string{"a": "main", "b": "a"},
+		},
+		{
+			name:          "parent isn't recorded",
+			branch:        "b",
+			relationships: map[string]string{"b": "gone"},
+		},
+		{
+			name:          "two branches",
+			branch:        "b",
+			relationships: map[string]string{"a": "b", "b": "a"},
+			want:          []string{"a", "b"},
+		},
+		{
+			name:          "starts from the alphabetically first branch",
+			branch:        "c",
+			relationships: map[string]string{"a": "c", "b": "a", "c": "b"},
+			want:          []string{"a", "c", "b"},
+		},
+		{
+			name:          "runs into a cycle further up",
+			branch:        "d",
+			relationships: map[string]string{"d": "c", "c": "b", "b": "c"},
+			want:          []string{"b", "c"},
+		},
+		{
+			name:          "own parent",
+			branch:        "a",
+			relationships: map[string]string{"a": "a"},
+			want:          []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findCycle(tt.branch, tt.relationships); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findCycle(%s) = %v, want %v", tt.branch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRepair(t *testing.T) {
+	tests := []struct {
+		name           string
+		issue          StackIssue
+		wantErr        bool
+		wantParents    map[string]string
+		wantForkPoints map[string]string
+		wantTrunk      string
+	}{
+		{
+			name:           "reparenting keeps the fork point",
+			issue:          StackIssue{Kind: IssueCycle, Branch: "a", newParent: "main"},
+			wantParents:    map[string]string{"a": "main", "b": "a"},
+			wantForkPoints: map[string]string{"a": "1111111", "b": "2222222"},
+			wantTrunk:      "main",
+		},
+		{
+			name:           "not building on the parent drops the fork point",
+			issue:          StackIssue{Kind: IssueNotAncestor, Branch: "b", newParent: "main"},
+			wantParents:    map[string]string{"a": "b", "b": "main"},
+			wantForkPoints: map[string]string{"a": "1111111"},
+			wantTrunk:      "main",
+		},
+		{
+			name:           "stale branch is forgotten",
+			issue:          StackIssue{Kind: IssueStaleBranch, Branch: "b"},
+			wantParents:    map[string]string{"a": "b"},
+			wantForkPoints: map[string]string{"a": "1111111"},
+			wantTrunk:      "main",
+		},
+		{
+			name:           "trunk is replaced",
+			issue:          StackIssue{Kind: IssueTrunkMismatch, Branch: "main", newTrunk: "develop"},
+			wantParents:    map[string]string{"a": "b", "b": "a"},
+			wantForkPoints: map[string]string{"a": "1111111", "b": "2222222"},
+			wantTrunk:      "develop",
+		},
+		{
+			name:    "no trunk to use",
+			issue:   StackIssue{Kind: IssueTrunkMissing, Branch: "main"},
+			wantErr: true,
+		},
+		{
+			name:    "no parent to move onto",
+			issue:   StackIssue{Kind: IssueDanglingParent, Branch: "a"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &StackConfig{
+				Relationships: map[string]string{"a": "b", "b": "a"},
+				ForkPoints:    map[string]string{"a": "1111111", "b": "2222222"},
+			}
+			config.Metadata.MainBranch = "main"
+
+			err := applyRepair(config, tt.issue)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(config.Relationships, tt.wantParents) {
+				t.Errorf("relationships: got %v, want %v", config.Relationships, tt.wantParents)
+			}
+			if !reflect.DeepEqual(config.ForkPoints, tt.wantForkPoints) {
+				t.Errorf("fork points: got %v, want %v", config.ForkPoints, tt.wantForkPoints)
+			}
+			if config.Metadata.MainBranch != tt.wantTrunk {
+				t.Errorf("trunk: got %s, want %s", config.Metadata.MainBranch, tt.wantTrunk)
+			}
+		})
+	}
+}
+
+func TestDiagnoseAndRepair(t *testing.T) {
+	g := fixtureRepo(t, [][]string{
+		commit("m1"),
+		checkout("a", true), commit("a1"),
+		checkout("b", true), commit("b1"),
+		checkout("main", false),
+		checkout("c", true), commit("c1"),
+		checkout("main", false),
+		checkout("d", true), commit("d1"),
+		checkout("main", false),
+		checkout("e", true), commit("e1"),
+		checkout("main", false),
+	})
+
+	// Record relationships no command would write
+	err := g.UpdateStackConfig(func(config *StackConfig) error {
+		config.Metadata.MainBranch = "main"
+		config.Relationships = map[string]string{
+			"a":     "b",
+			"b":     "a",
+			"c":     "c",
+			"d":     "gone",
+			"e":     "c",
+			"stale": "main",
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := g.Diagnose()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type found struct {
+		Kind      IssueKind
+		Branch    string
+		NewParent string
+	}
+	want := []found{
+		{Kind: IssueCycle, Branch: "a", NewParent: "main"},
+		{Kind: IssueSelfParent, Branch: "c", NewParent: "main"},
+		{Kind: IssueDanglingParent, Branch: "d", NewParent: "main"},
+		{Kind: IssueNotAncestor, Branch: "e", NewParent: "main"},
+		{Kind: IssueStaleBranch, Branch: "stale"},
+	}
+	var got []found
+	for _, issue := range issues {
+		got = append(got, found{Kind: issue.Kind, Branch: issue.Branch, NewParent: issue.newParent})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got issues %+v, want %+v", got, want)
+	}
+
+	for _, issue := range issues {
+		if err := g.Repair(issue); err != nil {
+			t.Fatalf("repairing %s: %v", issue.Kind, err)
+		}
+	}
+
+	config, err := g.LoadStackConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantParents := map[string]string{"a": "main", "b": "a", "c": "main", "d": "main", "e": "main"}
+	if !reflect.DeepEqual(config.Relationships, wantParents) {
+		t.Errorf("relationships after repair: got %v, want %v", config.Relationships, wantParents)
+	}
+
+	issues, err = g.Diagnose()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("expected no issues after repair, got %+v", issues)
+	}
+}
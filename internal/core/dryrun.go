@@ -0,0 +1,221 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PlannedCommand is a mutating operation that was recorded instead of executed during a dry run
+type PlannedCommand struct {
+	Description string
+	Args        []string // Git arguments, empty for non-git operations such as metadata writes
+}
+
+// String returns the git command line for the operation
+func (c PlannedCommand) String() string {
+	if len(c.Args) == 0 {
+		return ""
+	}
+	return "git " + strings.Join(c.Args, " ")
+}
+
+// mutatingCommands are git subcommands that always change refs, the working tree or remotes
+var mutatingCommands = map[string]bool{
+	"checkout":    true,
+	"switch":      true,
+	"rebase":      true,
+	"reset":       true,
+	"merge":       true,
+	"cherry-pick": true,
+	"commit":      true,
+	"push":        true,
+	"fetch":       true,
+	"update-ref":  true,
+	"stash":       true,
+	"worktree":    true,
+}
+
+// splitSubcommand separates global options like `-c key=value` from the subcommand and its arguments
+func splitSubcommand(args []string) (string, []string) {
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-c", "-C":
+			i++ // Skip the option's value
+		default:
+			return args[i], args[i+1:]
+		}
+	}
+	return "", nil
+}
+
+// isMutating checks if a git command would change refs, the working tree or remotes
+func isMutating(args []string) bool {
+	subcommand, rest := splitSubcommand(args)
+
+	// Listing worktrees is read-only, adding and removing them isn't
+	if subcommand == "worktree" && len(rest) > 0 && rest[0] == "list" {
+		return false
+	}
+
+	if mutatingCommands[subcommand] {
+		return true
+	}
+
+	switch subcommand {
+	case "branch":
+		// Listing forms (`branch`, `branch -r`, `branch --merged x`) are read-only
+		for i := 0; i < len(rest); i++ {
+			switch rest[i] {
+			case "-D", "-d", "-f", "-m", "-M", "--delete", "--force", "--move", "--set-upstream-to", "-u":
+				return true
+			case "--merged", "--no-merged", "--contains", "--no-contains", "--format", "--sort":
+				i++ // Skip the option's value
+			default:
+				if !strings.HasPrefix(rest[i], "-") {
+					return true // Creating a branch
+				}
+			}
+		}
+		return false
+	case "config":
+		// `config <key>` reads, `config <key> <value>` and the editing options write
+		var positional int
+		for i := 0; i < len(rest); i++ {
+			switch rest[i] {
+			case "--unset", "--unset-all", "--add", "--replace-all", "--remove-section", "--rename-section", "--edit", "-e":
+				return true
+			case "--get", "--get-all", "--get-regexp", "--get-urlmatch", "--list", "-l":
+				return false
+			case "--file", "-f", "--blob", "--type", "--default":
+				i++ // Skip the option's value
+			default:
+				if !strings.HasPrefix(rest[i], "-") {
+					positional++
+				}
+			}
+		}
+		return positional > 1
+	}
+
+	return false
+}
+
+// recordPlanned adds a git command to the dry run plan
+func (g *GitExecutor) recordPlanned(args []string) {
+	g.Plan = append(g.Plan, PlannedCommand{
+		Description: g.describe(args),
+		Args:        append([]string(nil), args...),
+	})
+
+	// Track checkouts so later steps see the branch they would be on
+	subcommand, rest := splitSubcommand(args)
+	if subcommand == "checkout" && len(rest) > 0 {
+		if rest[0] == "-b" && len(rest) > 1 {
+			g.plannedHead = rest[1]
+		} else if !strings.HasPrefix(rest[0], "-") {
+			g.plannedHead = rest[0]
+		}
+	}
+}
+
+// recordPlannedWrite adds a non-git operation, such as a metadata write, to the dry run plan
+func (g *GitExecutor) recordPlannedWrite(description string) {
+	// Collapse repeated writes of the same file into one step
+	if len(g.Plan) > 0 && g.Plan[len(g.Plan)-1].Description == description {
+		return
+	}
+	g.Plan = append(g.Plan, PlannedCommand{Description: description})
+}
+
+// describe returns a human readable summary of a mutating git command
+func (g *GitExecutor) describe(args []string) string {
+	subcommand, rest := splitSubcommand(args)
+
+	switch subcommand {
+	case "checkout":
+		if len(rest) > 2 && rest[0] == "-b" {
+			return fmt.Sprintf("Create %s from %s and check it out", rest[1], rest[2])
+		}
+		if len(rest) > 0 {
+			return fmt.Sprintf("Check out %s", rest[0])
+		}
+	case "rebase":
+		if len(rest) > 2 && rest[0] == "--onto" {
+			return fmt.Sprintf("Rebase %s onto %s (commits after %s)", g.plannedBranch(), rest[1], shortSHA(rest[2]))
+		}
+		if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+			return fmt.Sprintf("Rebase %s onto %s", g.plannedBranch(), rest[0])
+		}
+	case "push":
+		// `push <remote> <branch>` names the branch, otherwise the current branch is pushed
+		branch := g.plannedBranch()
+		var positional []string
+		withLease, deleting := false, false
+		for _, arg := range rest {
+			if strings.HasPrefix(arg, "--force-with-lease") {
+				withLease = true
+			} else if arg == "--delete" || arg == "-d" {
+				deleting = true
+			} else if !strings.HasPrefix(arg, "-") {
+				positional = append(positional, arg)
+			}
+		}
+		if len(positional) > 1 {
+			var branches []string
+			for _, refspec := range positional[1:] {
+				branches = append(branches, strings.SplitN(refspec, ":", 2)[0])
+			}
+			branch = strings.Join(branches, ", ")
+		}
+
+		if deleting {
+			return fmt.Sprintf("Delete %s on the remote", branch)
+		}
+		if withLease {
+			return fmt.Sprintf("Push %s to the remote with lease", branch)
+		}
+		return fmt.Sprintf("Push %s to the remote", branch)
+	case "fetch":
+		return "Fetch from the remote"
+	case "update-ref":
+		var positional []string
+		for i := 0; i < len(rest); i++ {
+			if rest[i] == "-m" {
+				i++ // Skip the reflog message
+			} else if !strings.HasPrefix(rest[i], "-") {
+				positional = append(positional, rest[i])
+			}
+		}
+		if len(positional) > 1 {
+			return fmt.Sprintf("Move %s to %s without a checkout", strings.TrimPrefix(positional[0], "refs/heads/"), shortSHA(positional[1]))
+		}
+	case "reset":
+		return fmt.Sprintf("Reset %s and the working tree", g.plannedBranch())
+	case "branch":
+		if len(rest) > 1 && (rest[0] == "-D" || rest[0] == "-d") {
+			return fmt.Sprintf("Delete branch %s", rest[1])
+		}
+		if len(rest) > 2 && rest[0] == "-f" {
+			return fmt.Sprintf("Move %s to %s", rest[1], shortSHA(rest[2]))
+		}
+	}
+
+	return fmt.Sprintf("Run git %s", subcommand)
+}
+
+// plannedBranch returns the branch that would be checked out at this point of the plan
+func (g *GitExecutor) plannedBranch() string {
+	branch, err := g.GetCurrentBranch()
+	if err != nil {
+		return "the current branch"
+	}
+	return branch
+}
+
+// shortSHA abbreviates a commit SHA for display
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
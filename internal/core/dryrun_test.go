@@ -0,0 +1,72 @@
+package core
+
+import "testing"
+
+func TestIsMutating(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"checkout", "main"}, true},
+		{[]string{"-c", "core.hooksPath=/dev/null", "rebase", "main"}, true},
+		{[]string{"push", "origin", "--delete", "a"}, true},
+		{[]string{"rev-parse", "--verify", "main"}, false},
+		{[]string{"log", "-p", "main..a"}, false},
+		{[]string{"patch-id", "--stable"}, false},
+		{[]string{"worktree", "list", "--porcelain"}, false},
+		{[]string{"worktree", "add", "/tmp/wt", "a"}, true},
+		{[]string{"branch"}, false},
+		{[]string{"branch", "-r"}, false},
+		{[]string{"branch", "--merged", "main"}, false},
+		{[]string{"branch", "--format", "%(refname:short)"}, false},
+		{[]string{"branch", "feature"}, true},
+		{[]string{"branch", "-D", "feature"}, true},
+		{[]string{"branch", "-f", "feature", "abc123"}, true},
+		{[]string{"config", "user.email"}, false},
+		{[]string{"config", "--get", "user.email"}, false},
+		{[]string{"config", "-z", "--get-regexp", "^stacksmith\\."}, false},
+		{[]string{"config", "--list"}, false},
+		{[]string{"config", "--type", "bool", "stacksmith.autoStash"}, false},
+		{[]string{"config", "--file", "other", "user.email"}, false},
+		{[]string{"config", "user.email", "sam@example.com"}, true},
+		{[]string{"config", "--type", "bool", "stacksmith.autoStash", "true"}, true},
+		{[]string{"config", "--unset", "branch.a.stacksmith-parent"}, true},
+		{[]string{"config", "--add", "remote.origin.fetch", "+refs/x:refs/x"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := isMutating(tt.args); got != tt.want {
+			t.Errorf("isMutating(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	g := fixtureRepo(t, [][]string{commit("m1")})
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"checkout", "-b", "feature", "main"}, "Create feature from main and check it out"},
+		{[]string{"checkout", "feature"}, "Check out feature"},
+		{[]string{"rebase", "--onto", "main", "0123456789abcdef"}, "Rebase main onto main (commits after 0123456)"},
+		{[]string{"rebase", "origin/main"}, "Rebase main onto origin/main"},
+		{[]string{"push", "origin", "a", "b"}, "Push a, b to the remote"},
+		{[]string{"push", "--force-with-lease", "origin", "a:a"}, "Push a to the remote with lease"},
+		{[]string{"push", "--force-with-lease"}, "Push main to the remote with lease"},
+		{[]string{"push", "origin", "--delete", "a"}, "Delete a on the remote"},
+		{[]string{"fetch", "origin"}, "Fetch from the remote"},
+		{[]string{"update-ref", "-m", "restack", "refs/heads/a", "0123456789abcdef"}, "Move a to 0123456 without a checkout"},
+		{[]string{"reset", "--hard", "abc"}, "Reset main and the working tree"},
+		{[]string{"branch", "-D", "a"}, "Delete branch a"},
+		{[]string{"branch", "-f", "a", "0123456789abcdef"}, "Move a to 0123456"},
+		{[]string{"stash", "push"}, "Run git stash"},
+	}
+
+	for _, tt := range tests {
+		if got := g.describe(tt.args); got != tt.want {
+			t.Errorf("describe(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// fixtureRepo creates a repository in a temporary directory by running each git command in it.
+// Relationships are kept in memory.
+func fixtureRepo(t *testing.T, commands [][]string) *GitExecutor {
+	t.Helper()
+	g := NewGitExecutor(t.TempDir())
+	g.Store = NewMemoryStore()
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	setup := append([][]string{{"init", "-q", "-b", "main"}}, commands...)
+	for _, args := range setup {
+		if _, err := g.Execute(args...); err != nil {
+			t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+		}
+	}
+	return g
+}
+
+// commit makes an empty commit with the given message
+func commit(message string) []string {
+	return []string{"commit", "-q", "--allow-empty", "-m", message}
+}
+
+// merge merges branch into the current branch with a merge commit
+func merge(branch string) []string {
+	return []string{"merge", "-q", "--no-ff", "--no-edit", branch}
+}
+
+// checkout switches to a branch, creating it from the current one with create
+func checkout(branch string, create bool) []string {
+	if create {
+		return []string{"checkout", "-q", "-b", branch}
+	}
+	return []string{"checkout", "-q", branch}
+}
+
+// run runs git commands in a fixture repository, failing the test on the first error
+func run(t *testing.T, g *GitExecutor, commands ...[]string) {
+	t.Helper()
+	for _, args := range commands {
+		if _, err := g.Execute(args...); err != nil {
+			t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+		}
+	}
+}
+
+// stackFrom builds a BranchStack from child to parent pairs, "" for a branch without a parent
+func stackFrom(parents map[string]string) *BranchStack {
+	stack := &BranchStack{AllNodes: make(map[string]*BranchNode), MainBranch: "main"}
+	for branch := range parents {
+		stack.AllNodes[branch] = &BranchNode{Name: branch}
+	}
+	for branch, parent := range parents {
+		if parent == "" {
+			continue
+		}
+		node, parentNode := stack.AllNodes[branch], stack.AllNodes[parent]
+		node.Parent = parentNode
+		parentNode.Children = append(parentNode.Children, node)
+	}
+	return stack
+}
@@ -0,0 +1,42 @@
+package core
+
+import "strings"
+
+// FoldBranch: Move parent up to include a branch's commits, as they are or squashed into one
+// commit. The branch must already be built on parent's tip. It is left for the caller to delete.
+func (g *GitExecutor) FoldBranch(branch, parent string, squash bool) error {
+	branchSHA, err := g.resolveCommit(branch)
+	if err != nil {
+		return err
+	}
+	parentSHA, err := g.resolveCommit(parent)
+	if err != nil {
+		return err
+	}
+
+	newTip := branchSHA
+	if squash && branchSHA != parentSHA {
+		// Keep every folded commit's message, oldest first, as git does when squashing
+		messages, err := g.Execute("log", "--reverse", "--format=%B", parentSHA+".."+branchSHA)
+		if err != nil {
+			return err
+		}
+
+		output, err := g.ExecuteWithInput(nil, strings.TrimSpace(messages)+"\n",
+			"commit-tree", branchSHA+"^{tree}", "-p", parentSHA, "-F", "-")
+		if err != nil {
+			return err
+		}
+		newTip = strings.TrimSpace(output)
+	}
+
+	// A checked out parent has to bring its working tree along, keeping any local changes
+	current, err := g.GetCurrentBranch()
+	if err == nil && current == parent {
+		_, err = g.Execute("reset", "--keep", newTip)
+		return err
+	}
+
+	_, err = g.Execute("update-ref", "refs/heads/"+parent, newTip, parentSHA)
+	return err
+}
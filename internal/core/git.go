@@ -2,8 +2,11 @@ package core
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -22,6 +25,15 @@ func (e *GitError) Error() string {
 		e.Err, strings.Join(e.Args, " "), e.Stderr)
 }
 
+// ExitCode returns the status git exited with, or -1 if it didn't run to completion
+func (e *GitError) ExitCode() int {
+	var exitErr *exec.ExitError
+	if errors.As(e.Err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
 // BranchNotFoundError represents an error when a branch doesn't exist
 type BranchNotFoundError struct {
 	BranchName string
@@ -51,9 +63,46 @@ func (e *MergeConflictError) Error() string {
 	return fmt.Sprintf("merge conflict when rebasing %s onto %s", e.Branch, e.Target)
 }
 
+// StashConflictError represents stashed changes that could not be reapplied cleanly
+type StashConflictError struct {
+	StashRef string
+	Err      error
+}
+
+func (e *StashConflictError) Error() string {
+	return fmt.Sprintf("could not reapply stashed changes from %s: %s", e.StashRef, e.Err)
+}
+
+// SchemaVersionError represents a stack.yml written by a newer stacksmith than this one
+type SchemaVersionError struct {
+	Found     int
+	Supported int
+}
+
+func (e *SchemaVersionError) Error() string {
+	return fmt.Sprintf("stack.yml uses schema version %d but this stacksmith only supports up to version %d, please upgrade stacksmith", e.Found, e.Supported)
+}
+
+// StackLockedError represents stack.yml being locked by another process for longer than we wait
+type StackLockedError struct {
+	LockPath string
+}
+
+func (e *StackLockedError) Error() string {
+	return fmt.Sprintf("stack metadata is locked by another stacksmith process (%s)", e.LockPath)
+}
+
 // GitExecutor handles running Git commands
 type GitExecutor struct {
-	WorkDir string // Optional working directory
+	WorkDir string     // Optional working directory
+	DryRun  bool       // Record mutating commands in Plan instead of running them
+	Store   StackStore // Where relationships are kept, stack.yml when nil
+	NoCache bool       // Compute the stack from scratch instead of using the cache
+
+	Plan        []PlannedCommand
+	plannedHead string // Branch a dry run would have checked out
+
+	version []int // Cached git version, see SupportsVersion
 }
 
 // NewGitExecutor creates a new GitExecutor
@@ -65,11 +114,27 @@ func NewGitExecutor(workDir string) *GitExecutor {
 
 // Execute runs a git command and returns its output
 func (g *GitExecutor) Execute(args ...string) (string, error) {
+	return g.ExecuteWithInput(nil, "", args...)
+}
+
+// ExecuteWithInput runs a git command with extra environment variables and standard input
+func (g *GitExecutor) ExecuteWithInput(env []string, stdin string, args ...string) (string, error) {
+	if g.DryRun && isMutating(args) {
+		g.recordPlanned(args)
+		return "", nil
+	}
+
 	cmd := exec.Command("git", args...)
 
 	if g.WorkDir != "" {
 		cmd.Dir = g.WorkDir
 	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -103,9 +168,8 @@ func (g *GitExecutor) Execute(args ...string) (string, error) {
 			currentBranch, _ := g.GetCurrentBranch()
 			targetBranch := ""
 			for i, arg := range args {
-				if arg == "rebase" && i+1 < len(args) {
+				if (arg == "rebase" || arg == "--onto") && i+1 < len(args) {
 					targetBranch = args[i+1]
-					break
 				}
 			}
 			return "", &MergeConflictError{Branch: currentBranch, Target: targetBranch}
@@ -134,8 +198,38 @@ func (g *GitExecutor) Execute(args ...string) (string, error) {
 	return stdout.String(), nil
 }
 
+// SupportsVersion checks if the installed git is at least major.minor
+func (g *GitExecutor) SupportsVersion(major, minor int) bool {
+	if g.version == nil {
+		g.version = []int{0, 0}
+
+		// Output looks like "git version 2.39.5" or "git version 2.39.5.windows.1"
+		output, err := g.Execute("version")
+		if err == nil {
+			fields := strings.Fields(output)
+			if len(fields) >= 3 {
+				parts := strings.Split(fields[2], ".")
+				for i := 0; i < len(parts) && i < 2; i++ {
+					if n, err := strconv.Atoi(parts[i]); err == nil {
+						g.version[i] = n
+					}
+				}
+			}
+		}
+	}
+
+	if g.version[0] != major {
+		return g.version[0] > major
+	}
+	return g.version[1] >= minor
+}
+
 // GetCurrentBranch returns the name of the current branch
 func (g *GitExecutor) GetCurrentBranch() (string, error) {
+	if g.DryRun && g.plannedHead != "" {
+		return g.plannedHead, nil
+	}
+
 	output, err := g.Execute("rev-parse", "--abbrev-ref", "HEAD")
 	if err != nil {
 		return "", err
@@ -183,6 +277,154 @@ func (g *GitExecutor) RebaseBranch(targetBranch string) error {
 	return err
 }
 
+// IsWorkingTreeDirty checks if tracked files have uncommitted changes
+func (g *GitExecutor) IsWorkingTreeDirty() (bool, error) {
+	output, err := g.Execute("status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) != "", nil
+}
+
+// HasLocalChanges checks if the working tree has uncommitted or untracked changes
+func (g *GitExecutor) HasLocalChanges() (bool, error) {
+	output, err := g.Execute("status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) != "", nil
+}
+
+// StashChanges stashes all local changes, including untracked files, and returns the stash commit
+func (g *GitExecutor) StashChanges(message string) (string, error) {
+	_, err := g.Execute("stash", "push", "--include-untracked", "-m", message)
+	if err != nil {
+		return "", err
+	}
+
+	// Nothing was stashed during a dry run
+	if g.DryRun {
+		return "", nil
+	}
+
+	output, err := g.Execute("rev-parse", "--verify", "refs/stash")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// RestoreStash pops the stash entry created by StashChanges
+func (g *GitExecutor) RestoreStash(stashSHA string) error {
+	output, err := g.Execute("stash", "list", "--format=%H")
+	if err != nil {
+		return err
+	}
+
+	// Find the entry by commit, since later stashes shift its index
+	ref := ""
+	for i, sha := range strings.Split(strings.TrimSpace(output), "\n") {
+		if sha == stashSHA {
+			ref = fmt.Sprintf("stash@{%d}", i)
+			break
+		}
+	}
+	if ref == "" {
+		return fmt.Errorf("stash %s no longer exists", stashSHA)
+	}
+
+	if _, err := g.Execute("stash", "pop", ref); err != nil {
+		return &StashConflictError{StashRef: ref, Err: err}
+	}
+	return nil
+}
+
+// IsRebaseInProgress checks if a rebase was stopped part way through
+func (g *GitExecutor) IsRebaseInProgress() (bool, error) {
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		output, err := g.Execute("rev-parse", "--git-path", dir)
+		if err != nil {
+			return false, err
+		}
+
+		path := strings.TrimSpace(output)
+		if g.WorkDir != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(g.WorkDir, path)
+		}
+		if _, err := os.Stat(path); err == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ContinueRebase continues a stopped rebase without opening an editor
+func (g *GitExecutor) ContinueRebase() error {
+	_, err := g.Execute("-c", "core.editor=true", "rebase", "--continue")
+	return err
+}
+
+// AbortRebase aborts a stopped rebase
+func (g *GitExecutor) AbortRebase() error {
+	_, err := g.Execute("rebase", "--abort")
+	return err
+}
+
+// AddWorktree checks a branch out into a new linked worktree at path
+func (g *GitExecutor) AddWorktree(path, branch string) error {
+	_, err := g.Execute("worktree", "add", path, branch)
+	return err
+}
+
+// RemoveWorktree deletes a linked worktree, discarding anything left in it
+func (g *GitExecutor) RemoveWorktree(path string) error {
+	_, err := g.Execute("worktree", "remove", "--force", path)
+	return err
+}
+
+// GetBranchSHA returns the commit SHA a local branch points at
+func (g *GitExecutor) GetBranchSHA(branch string) (string, error) {
+	output, err := g.Execute("rev-parse", "--verify", "refs/heads/"+branch)
+	if err != nil {
+		return "", &BranchNotFoundError{BranchName: branch}
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// ResetBranch moves a local branch to a commit, resetting the working tree if it is checked out
+func (g *GitExecutor) ResetBranch(branch, commitSHA string) error {
+	currentBranch, err := g.GetCurrentBranch()
+	if err != nil {
+		return err
+	}
+
+	if currentBranch == branch {
+		_, err = g.Execute("reset", "--hard", commitSHA)
+		return err
+	}
+
+	_, err = g.Execute("branch", "-f", branch, commitSHA)
+	return err
+}
+
+// RebaseBranchOnto moves the current branch's commits after upstream onto newBase
+func (g *GitExecutor) RebaseBranchOnto(newBase, upstream string) error {
+	_, err := g.Execute("rebase", "--onto", newBase, upstream)
+	return err
+}
+
+// IsAncestor checks if commit is reachable from descendant
+func (g *GitExecutor) IsAncestor(commit, descendant string) bool {
+	_, err := g.Execute("merge-base", "--is-ancestor", commit, descendant)
+	return err == nil
+}
+
+// PushNamedBranch pushes a branch to origin with force-with-lease without checking it out
+func (g *GitExecutor) PushNamedBranch(branch string) error {
+	_, err := g.Execute("push", "--force-with-lease", "origin", branch)
+	return err
+}
+
 // PushBranch pushes the current branch with force-with-lease
 func (g *GitExecutor) PushBranch() error {
 	_, err := g.Execute("push", "--force-with-lease")
@@ -245,27 +487,139 @@ func (g *GitExecutor) ListRemoteBranches() ([]string, error) {
 	return branches, nil
 }
 
-// IsBranchMerged checks if a branch has been merged into another branch
-func (g *GitExecutor) IsBranchMerged(branch, target string) (bool, error) {
-	// Check if all commits in branch are contained in target
-	output, err := g.Execute("branch", "--merged", target)
+// IsMergedIntoTrunk checks if a branch's changes have landed in trunk, including
+// squash and rebase merges that `git branch --merged` cannot see.
+// forkPoint is the commit the branch was based on, used to ignore branches with no commits.
+func (g *GitExecutor) IsMergedIntoTrunk(branch, trunk, forkPoint string) (bool, error) {
+	branchSHA, err := g.resolveCommit(branch)
+	if err != nil {
+		return false, err
+	}
+	return g.landedInTrunk(branchSHA, trunk, forkPoint, g.IsAncestor(branchSHA, trunk), g.resolveTree)
+}
+
+// landedInTrunk does the work of IsMergedIntoTrunk for a branch tip, given whether trunk
+// already contains it and how to look up a commit's tree
+func (g *GitExecutor) landedInTrunk(branchSHA, trunk, forkPoint string, inTrunk bool, resolveTree func(string) (string, error)) (bool, error) {
+	// A branch without commits of its own has nothing to merge
+	if branchSHA == forkPoint {
+		return false, nil
+	}
+
+	// Regular merge or fast-forward
+	if inTrunk {
+		return true, nil
+	}
+
+	// Rebase merge: every commit has a patch-equivalent commit in trunk
+	if equivalent, err := g.isPatchEquivalent(trunk, branchSHA); err != nil || equivalent {
+		return equivalent, err
+	}
+
+	// Squash merge: the branch's combined diff has a patch-equivalent commit in trunk
+	mergeBase, err := g.Execute("merge-base", trunk, branchSHA)
 	if err != nil {
 		return false, err
 	}
+	if squashed, err := g.isSquashedInto(trunk, strings.TrimSpace(mergeBase), branchSHA); err != nil || squashed {
+		return squashed, err
+	}
+
+	// Tree equivalence: trunk has exactly the branch's content
+	trunkTree, err := resolveTree(trunk)
+	if err != nil {
+		return false, err
+	}
+	branchTree, err := resolveTree(branchSHA)
+	if err != nil {
+		return false, err
+	}
+	return trunkTree == branchTree, nil
+}
 
-	// Parse the output to find the branch
-	branches := strings.Split(strings.TrimSpace(output), "\n")
-	for _, b := range branches {
-		b = strings.TrimSpace(b)
-		b = strings.TrimPrefix(b, "* ")
-		if b == branch {
+// isSquashedInto checks if a commit in mergeBase..trunk has the same patch as everything the
+// branch changed since mergeBase, comparing patch IDs so that no commit has to be written
+func (g *GitExecutor) isSquashedInto(trunk, mergeBase, branchSHA string) (bool, error) {
+	diff, err := g.Execute("diff-tree", "-p", "--no-color", mergeBase, branchSHA)
+	if err != nil {
+		return false, err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return false, nil
+	}
+	branchID, err := g.ExecuteWithInput(nil, diff, "patch-id", "--stable")
+	if err != nil {
+		return false, err
+	}
+	branchFields := strings.Fields(branchID)
+	if len(branchFields) == 0 {
+		return false, nil
+	}
+
+	trunkLog, err := g.Execute("log", "-p", "--no-color", "--no-merges", mergeBase+".."+trunk)
+	if err != nil || strings.TrimSpace(trunkLog) == "" {
+		return false, err
+	}
+	trunkIDs, err := g.ExecuteWithInput(nil, trunkLog, "patch-id", "--stable")
+	if err != nil {
+		return false, err
+	}
+
+	for _, line := range strings.Split(trunkIDs, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == branchFields[0] {
 			return true, nil
 		}
 	}
-
 	return false, nil
 }
 
+// isPatchEquivalent checks if every commit in upstream..head has an equivalent patch in upstream
+func (g *GitExecutor) isPatchEquivalent(upstream, head string) (bool, error) {
+	output, err := g.Execute("cherry", upstream, head)
+	if err != nil {
+		return false, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return false, nil
+	}
+
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "-") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// resolveTree returns the tree SHA of a commit
+func (g *GitExecutor) resolveTree(rev string) (string, error) {
+	output, err := g.Execute("rev-parse", "--verify", rev+"^{tree}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// DeleteBranch force deletes a local branch
+func (g *GitExecutor) DeleteBranch(branch string) error {
+	_, err := g.Execute("branch", "-D", branch)
+	return err
+}
+
+// DeleteRemoteBranch deletes a branch on origin
+func (g *GitExecutor) DeleteRemoteBranch(branch string) error {
+	_, err := g.Execute("push", "origin", "--delete", branch)
+	return err
+}
+
+// HasRemoteBranch checks if the last fetch saw the branch on origin
+func (g *GitExecutor) HasRemoteBranch(branch string) bool {
+	_, err := g.Execute("rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+branch)
+	return err == nil
+}
+
 // GetAheadBehind returns the ahead/behind counts for two branches
 func (g *GitExecutor) GetAheadBehind(branch, target string) (int, int, error) {
 	// Run: git rev-list --left-right --count <target>...<branch>
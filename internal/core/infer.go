@@ -0,0 +1,178 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Confidence is how sure parent inference is about a guess
+type Confidence int
+
+const (
+	ConfidenceLow    Confidence = iota // Shown as a suggestion only
+	ConfidenceMedium                   // Recorded, a tie was broken in favour of trunk or a tracked branch
+	ConfidenceHigh                     // Recorded, the only branch the history points at
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceHigh:
+		return "high"
+	case ConfidenceMedium:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// ParentCandidate is a branch that could be the parent of another, scored by how the two relate
+type ParentCandidate struct {
+	Branch   string
+	Distance int  // Commits on the child since its merge base with the candidate
+	Unique   int  // Commits on the candidate that the child doesn't have
+	Trunk    bool // Candidate is the trunk
+	Tracked  bool // Candidate already has a recorded place in the stack
+}
+
+// ParentGuess is the inferred parent of a branch with no recorded relationship
+type ParentGuess struct {
+	Branch     string
+	Parent     string
+	Confidence Confidence
+	Candidates []ParentCandidate // Every candidate considered, best first
+}
+
+// Reason: Explain why the parent was picked
+func (p *ParentGuess) Reason() string {
+	best := p.Candidates[0]
+	switch {
+	case best.Distance == 0:
+		return fmt.Sprintf("%s and %s point at the same commit", p.Branch, p.Parent)
+	case best.Unique > 0 && !best.Trunk:
+		return fmt.Sprintf("%s shares history with %s, which has %d commit(s) %s doesn't", p.Branch, p.Parent, best.Unique, p.Branch)
+	case p.Confidence == ConfidenceHigh:
+		return fmt.Sprintf("%s is %d commit(s) ahead of %s", p.Branch, best.Distance, p.Parent)
+	default:
+		return fmt.Sprintf("%s is %d commit(s) ahead of %s and of %s", p.Branch, best.Distance, p.Parent, p.Candidates[1].Branch)
+	}
+}
+
+// better: Rank candidates by fewest commits since the merge base, then fewest commits the
+// child is missing, then trunk, then tracked branches, then name so the order is always the same
+func (c ParentCandidate) better(other ParentCandidate) bool {
+	if c.Distance != other.Distance {
+		return c.Distance < other.Distance
+	}
+	if c.Unique != other.Unique {
+		return c.Unique < other.Unique
+	}
+	if c.Trunk != other.Trunk {
+		return c.Trunk
+	}
+	if c.Tracked != other.Tracked {
+		return c.Tracked
+	}
+	return c.Branch < other.Branch
+}
+
+// InferParents: Guess the parent of each of the named branches from the other local branches,
+// mapped to their tips. Branches without a guess are left out. Tracked branches are the ones
+// with a recorded place in the stack, which are preferred over untracked ones when the history
+// can't tell them apart.
+func (g *GitExecutor) InferParents(names []string, branches map[string]string, trunk string, tracked map[string]bool) (map[string]*ParentGuess, error) {
+	counter, err := g.newCommitCounter(branches, names)
+	if err != nil {
+		return nil, err
+	}
+
+	guesses := make(map[string]*ParentGuess)
+	for _, name := range names {
+		guess, err := inferParent(name, branches, trunk, tracked, counter)
+		if err != nil {
+			return nil, err
+		}
+		if guess != nil {
+			guesses[name] = guess
+		}
+	}
+	return guesses, nil
+}
+
+// inferParent: Guess the parent of branch, or return nil if no branch shares any history
+// with it beyond trunk
+func inferParent(branch string, branches map[string]string, trunk string, tracked map[string]bool, counter commitCounter) (*ParentGuess, error) {
+	if branches[branch] == "" {
+		return nil, nil
+	}
+
+	// Counted from branch, a candidate's Behind is how far branch has moved past it
+	// and its Ahead is what branch is missing
+	counts, err := counter.counts(branch)
+	if err != nil {
+		return nil, err
+	}
+
+	// Branches sharing nothing beyond trunk are no closer than trunk itself
+	trunkDistance := -1
+	if count, exists := counts[trunk]; exists && trunk != branch {
+		trunkDistance = count.Behind
+	}
+
+	var candidates []ParentCandidate
+	for name := range branches {
+		if name == branch {
+			continue
+		}
+
+		count, exists := counts[name]
+		if !exists {
+			continue // Unrelated history
+		}
+		distance, unique := count.Behind, count.Ahead
+
+		if distance == 0 && unique > 0 {
+			continue // Candidate builds on branch, so it would be a child
+		}
+		if distance == 0 && name != trunk && !tracked[name] {
+			continue // Two untracked branches at the same commit, either could be the parent
+		}
+		if name != trunk && trunkDistance >= 0 && distance >= trunkDistance {
+			continue
+		}
+
+		candidates = append(candidates, ParentCandidate{
+			Branch:   name,
+			Distance: distance,
+			Unique:   unique,
+			Trunk:    name == trunk,
+			Tracked:  tracked[name],
+		})
+	}
+
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].better(candidates[j])
+	})
+
+	best := candidates[0]
+	guess := &ParentGuess{Branch: branch, Parent: best.Branch, Candidates: candidates}
+
+	// Only a candidate whose tip is in branch is certainly below it, anything else may be a
+	// sibling or a parent that moved on. Trunk moving on is expected, and it is always a safe
+	// parent. Ties are settled by preference, never by name.
+	switch {
+	case best.Distance == 0 || (best.Unique > 0 && !best.Trunk):
+		guess.Confidence = ConfidenceLow
+	case len(candidates) == 1 || candidates[1].Distance != best.Distance || candidates[1].Unique != best.Unique:
+		guess.Confidence = ConfidenceHigh
+	case best.Trunk != candidates[1].Trunk || best.Tracked != candidates[1].Tracked:
+		guess.Confidence = ConfidenceMedium
+	default:
+		guess.Confidence = ConfidenceLow
+	}
+
+	return guess, nil
+}
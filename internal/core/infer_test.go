@@ -0,0 +1,207 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+// fixedCounts is a commitCounter that returns the same counts whatever the base
+type fixedCounts map[string]aheadBehind
+
+func (f fixedCounts) counts(base string) (map[string]aheadBehind, error) {
+	return f, nil
+}
+
+func TestParentCandidateBetter(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b ParentCandidate
+		want bool
+	}{
+		{
+			name: "fewer commits since the merge base",
+			a:    ParentCandidate{Branch: "z", Distance: 1, Unique: 5},
+			b:    ParentCandidate{Branch: "a", Distance: 2, Trunk: true, Tracked: true},
+			want: true,
+		},
+		{
+			name: "fewer commits the child is missing",
+			a:    ParentCandidate{Branch: "z", Distance: 1, Unique: 0},
+			b:    ParentCandidate{Branch: "a", Distance: 1, Unique: 1, Trunk: true},
+			want: true,
+		},
+		{
+			name: "trunk",
+			a:    ParentCandidate{Branch: "z", Distance: 1, Trunk: true},
+			b:    ParentCandidate{Branch: "a", Distance: 1, Tracked: true},
+			want: true,
+		},
+		{
+			name: "tracked",
+			a:    ParentCandidate{Branch: "z", Distance: 1, Tracked: true},
+			b:    ParentCandidate{Branch: "a", Distance: 1},
+			want: true,
+		},
+		{
+			name: "name",
+			a:    ParentCandidate{Branch: "a", Distance: 1},
+			b:    ParentCandidate{Branch: "z", Distance: 1},
+			want: true,
+		},
+		{
+			name: "same candidate",
+			a:    ParentCandidate{Branch: "a", Distance: 1},
+			b:    ParentCandidate{Branch: "a", Distance: 1},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.better(tt.b); got != tt.want {
+				t.Errorf("%+v better than %+v = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if tt.want && tt.b.better(tt.a) {
+				t.Errorf("%+v and %+v are both better than each other", tt.a, tt.b)
+			}
+		})
+	}
+}
+
+func TestInferParent(t *testing.T) {
+	tests := []struct {
+		name           string
+		counts         fixedCounts // Counted from x, Behind is how far x is past the branch
+		tracked        map[string]bool
+		wantParent     string
+		wantConfidence Confidence
+		wantNone       bool
+	}{
+		{
+			name:           "only trunk",
+			counts:         fixedCounts{"x": {}, "main": {Behind: 2, Ahead: 3}},
+			wantParent:     "main",
+			wantConfidence: ConfidenceHigh,
+		},
+		{
+			name:           "branch between trunk and x",
+			counts:         fixedCounts{"x": {}, "main": {Behind: 3}, "a": {Behind: 1}},
+			wantParent:     "a",
+			wantConfidence: ConfidenceHigh,
+		},
+		{
+			name:           "closer branch wins over a tie further down",
+			counts:         fixedCounts{"x": {}, "main": {Behind: 4}, "a": {Behind: 1}, "b": {Behind: 2}, "c": {Behind: 2}},
+			wantParent:     "a",
+			wantConfidence: ConfidenceHigh,
+		},
+		{
+			name:           "tracked branch at the same commit",
+			counts:         fixedCounts{"x": {}, "main": {Behind: 2}, "a": {}},
+			tracked:        map[string]bool{"a": true},
+			wantParent:     "a",
+			wantConfidence: ConfidenceLow,
+		},
+		{
+			name:           "untracked branch at the same commit is skipped",
+			counts:         fixedCounts{"x": {}, "main": {Behind: 2}, "a": {}},
+			wantParent:     "main",
+			wantConfidence: ConfidenceHigh,
+		},
+		{
+			name:           "parent moved on",
+			counts:         fixedCounts{"x": {}, "main": {Behind: 3}, "a": {Behind: 1, Ahead: 2}},
+			wantParent:     "a",
+			wantConfidence: ConfidenceLow,
+		},
+		{
+			name:           "tie broken by a tracked branch",
+			counts:         fixedCounts{"x": {}, "main": {Behind: 3}, "a": {Behind: 1}, "b": {Behind: 1}},
+			tracked:        map[string]bool{"b": true},
+			wantParent:     "b",
+			wantConfidence: ConfidenceMedium,
+		},
+		{
+			name:           "tie only broken by name",
+			counts:         fixedCounts{"x": {}, "main": {Behind: 3}, "a": {Behind: 1}, "b": {Behind: 1}},
+			tracked:        map[string]bool{"a": true, "b": true},
+			wantParent:     "a",
+			wantConfidence: ConfidenceLow,
+		},
+		{
+			name:           "child of x is skipped",
+			counts:         fixedCounts{"x": {}, "main": {Behind: 2}, "c": {Ahead: 1}},
+			wantParent:     "main",
+			wantConfidence: ConfidenceHigh,
+		},
+		{
+			name:           "no closer than trunk",
+			counts:         fixedCounts{"x": {}, "main": {Behind: 2}, "a": {Behind: 2, Ahead: 1}},
+			wantParent:     "main",
+			wantConfidence: ConfidenceHigh,
+		},
+		{
+			name:     "unrelated history",
+			counts:   fixedCounts{"x": {}},
+			wantNone: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			branches := make(map[string]string)
+			for name := range tt.counts {
+				branches[name] = "sha-" + name
+			}
+			branches["unrelated"] = "sha-unrelated"
+
+			guess, err := inferParent("x", branches, "main", tt.tracked, tt.counts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantNone {
+				if guess != nil {
+					t.Fatalf("expected no guess, got %s", guess.Parent)
+				}
+				return
+			}
+			if guess == nil {
+				t.Fatal("expected a guess")
+			}
+			if guess.Parent != tt.wantParent || guess.Confidence != tt.wantConfidence {
+				t.Errorf("got %s (%s confidence), want %s (%s confidence)", guess.Parent, guess.Confidence, tt.wantParent, tt.wantConfidence)
+			}
+			if guess.Reason() == "" {
+				t.Error("expected a reason")
+			}
+		})
+	}
+}
+
+func TestInferParents(t *testing.T) {
+	g := fixtureRepo(t, [][]string{
+		commit("m1"),
+		checkout("a", true), commit("a1"),
+		checkout("b", true), commit("b1"), commit("b2"),
+		checkout("main", false), commit("m2"),
+		checkout("c", true), commit("c1"),
+	})
+	branches, err := g.getBranchesWithCommits()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	guesses, err := g.InferParents([]string{"a", "b", "c"}, branches, "main", map[string]bool{"a": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	for branch, guess := range guesses {
+		got[branch] = guess.Parent + " " + guess.Confidence.String()
+	}
+	want := map[string]string{"a": "main high", "b": "a high", "c": "main high"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
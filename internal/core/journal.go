@@ -0,0 +1,131 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OperationJournal records the progress of a multi-branch restack so that it
+// can be resumed or rolled back after a conflict
+type OperationJournal struct {
+	Command        string            `yaml:"command"`
+	StartedAt      time.Time         `yaml:"started_at"`
+	OriginalBranch string            `yaml:"original_branch"`
+	Stash          string            `yaml:"stash,omitempty"` // Stash holding the user's local changes
+	OriginalSHAs   map[string]string `yaml:"original_shas"`
+	Steps          []RestackStep     `yaml:"steps"`
+	CurrentStep    int               `yaml:"current_step"`
+	StepRebased    bool              `yaml:"step_rebased"`             // Current step's rebase has finished
+	PushAlso       []string          `yaml:"push_also,omitempty"`      // Branches changed before the restack, pushed along with it
+	DeleteRemote   []string          `yaml:"delete_remote,omitempty"`  // Remote branches to delete once everything is pushed
+	OriginalStack  *StackConfig      `yaml:"original_stack,omitempty"` // Recorded stack before the command changed it
+	Created        []string          `yaml:"created,omitempty"`        // Branches the command created
+}
+
+// NewOperationJournal creates a journal for the given restack steps
+func NewOperationJournal(command, originalBranch string, steps []RestackStep) *OperationJournal {
+	return &OperationJournal{
+		Command:        command,
+		StartedAt:      time.Now(),
+		OriginalBranch: originalBranch,
+		OriginalSHAs:   make(map[string]string),
+		Steps:          steps,
+	}
+}
+
+// Done returns true once every step has been completed
+func (j *OperationJournal) Done() bool {
+	return j.CurrentStep >= len(j.Steps)
+}
+
+// Current returns the step that is being worked on
+func (j *OperationJournal) Current() RestackStep {
+	return j.Steps[j.CurrentStep]
+}
+
+// Advance marks the current step as complete
+func (j *OperationJournal) Advance() {
+	j.CurrentStep++
+	j.StepRebased = false
+}
+
+// journalPath: Return the location of the operation journal. Each worktree has its own,
+// since an interrupted rebase belongs to the worktree it stopped in.
+func (g *GitExecutor) journalPath() (string, error) {
+	stacksmithDir, err := g.worktreeStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stacksmithDir, "operation.yml"), nil
+}
+
+// SaveJournal persists the operation journal
+func (g *GitExecutor) SaveJournal(journal *OperationJournal) error {
+	// A dry run has no progress worth resuming
+	if g.DryRun {
+		return nil
+	}
+
+	filePath, err := g.journalPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	yamlData, err := yaml.Marshal(journal)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filePath, yamlData, 0644)
+}
+
+// LoadJournal loads the operation journal, returning nil if no operation is in progress
+func (g *GitExecutor) LoadJournal() (*OperationJournal, error) {
+	filePath, err := g.journalPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var journal OperationJournal
+	if err := yaml.Unmarshal(data, &journal); err != nil {
+		return nil, err
+	}
+
+	if journal.OriginalSHAs == nil {
+		journal.OriginalSHAs = make(map[string]string)
+	}
+
+	return &journal, nil
+}
+
+// ClearJournal removes the operation journal once an operation has finished
+func (g *GitExecutor) ClearJournal() error {
+	if g.DryRun {
+		return nil
+	}
+
+	filePath, err := g.journalPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
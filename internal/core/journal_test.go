@@ -0,0 +1,94 @@
+package core
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJournalRoundTrip(t *testing.T) {
+	g := fixtureRepo(t, [][]string{commit("m1"), checkout("a", true), commit("a1")})
+
+	journal := NewOperationJournal("sync", "a", []RestackStep{
+		{Branch: "a", Parent: "main", Upstream: "1111111"},
+		{Branch: "b", Parent: "a", Worktree: "/elsewhere"},
+	})
+	journal.OriginalSHAs["a"] = branchSHA(t, g, "a")
+	journal.Stash = "2222222"
+	journal.PushAlso = []string{"c"}
+	journal.DeleteRemote = []string{"old"}
+	journal.Created = []string{"b"}
+	journal.OriginalStack = &StackConfig{Relationships: map[string]string{"a": "main"}}
+	journal.Advance()
+
+	if err := g.SaveJournal(journal); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := g.LoadJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded == nil {
+		t.Fatal("expected a journal")
+	}
+
+	if loaded.Command != "sync" || loaded.OriginalBranch != "a" || loaded.Stash != journal.Stash {
+		t.Errorf("got %+v, want %+v", loaded, journal)
+	}
+	if !reflect.DeepEqual(loaded.Steps, journal.Steps) || !reflect.DeepEqual(loaded.OriginalSHAs, journal.OriginalSHAs) {
+		t.Errorf("steps or SHAs: got %+v and %v, want %+v and %v", loaded.Steps, loaded.OriginalSHAs, journal.Steps, journal.OriginalSHAs)
+	}
+	if !reflect.DeepEqual(loaded.PushAlso, journal.PushAlso) || !reflect.DeepEqual(loaded.DeleteRemote, journal.DeleteRemote) || !reflect.DeepEqual(loaded.Created, journal.Created) {
+		t.Errorf("got %+v, want %+v", loaded, journal)
+	}
+	if loaded.OriginalStack == nil || loaded.OriginalStack.Relationships["a"] != "main" {
+		t.Errorf("original stack: got %+v", loaded.OriginalStack)
+	}
+	if loaded.CurrentStep != 1 || loaded.Current().Branch != "b" || loaded.Done() {
+		t.Errorf("progress: got step %d", loaded.CurrentStep)
+	}
+	loaded.Advance()
+	if !loaded.Done() {
+		t.Error("expected the journal to be done after the last step")
+	}
+
+	if err := g.ClearJournal(); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := g.LoadJournal(); err != nil || loaded != nil {
+		t.Errorf("after clearing: got %+v, %v", loaded, err)
+	}
+	if err := g.ClearJournal(); err != nil {
+		t.Errorf("clearing twice: %v", err)
+	}
+}
+
+func TestJournalNotWrittenInDryRun(t *testing.T) {
+	g := fixtureRepo(t, [][]string{commit("m1")})
+	g.DryRun = true
+
+	if err := g.SaveJournal(NewOperationJournal("sync", "main", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if journal, err := g.LoadJournal(); err != nil || journal != nil {
+		t.Errorf("got %+v, %v, want no journal", journal, err)
+	}
+}
+
+func TestJournalBelongsToItsWorktree(t *testing.T) {
+	g := fixtureRepo(t, [][]string{commit("m1"), checkout("a", true), commit("a1"), checkout("main", false)})
+	path := filepath.Join(t.TempDir(), "a")
+	run(t, g, []string{"worktree", "add", "-q", path, "a"})
+	other := g.InWorktree(path)
+
+	if err := other.SaveJournal(NewOperationJournal("sync", "a", []RestackStep{{Branch: "a", Parent: "main"}})); err != nil {
+		t.Fatal(err)
+	}
+
+	if journal, err := g.LoadJournal(); err != nil || journal != nil {
+		t.Errorf("main worktree: got %+v, %v, want no journal", journal, err)
+	}
+	if journal, err := other.LoadJournal(); err != nil || journal == nil {
+		t.Errorf("linked worktree: got %+v, %v, want its journal", journal, err)
+	}
+}
@@ -0,0 +1,260 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// metadataRef holds the shared stack metadata as a commit whose tree contains stack.yml
+	metadataRef = "refs/stacksmith/stack"
+	// remoteMetadataRef is the last copy of origin's metadata that was fetched or pushed
+	remoteMetadataRef = "refs/stacksmith/remotes/origin/stack"
+	// metadataFile is the name of the file inside the metadata commit's tree
+	metadataFile = "stack.yml"
+)
+
+// ErrMetadataDiverged is returned when origin's metadata has changes that were not fetched yet
+var ErrMetadataDiverged = errors.New("the remote stack metadata has changes you have not fetched")
+
+// SharedStack is the part of the stack config that is shared with other clones.
+// Fork points are left out because they only describe this clone's history.
+type SharedStack struct {
+	MainBranch    string            `yaml:"main_branch"`
+	Relationships map[string]string `yaml:"relationships"`
+}
+
+// MetadataConflict is a branch whose parent was changed both here and on the remote
+type MetadataConflict struct {
+	Branch string
+	Ours   string // Empty when the branch was removed here
+	Theirs string // Empty when the branch was removed on the remote
+}
+
+// MetadataMergeResult describes what fetching the remote metadata changed
+type MetadataMergeResult struct {
+	Shared    bool     // False when the remote has no stack metadata yet
+	Updated   []string // Branches whose parent was taken from the remote
+	Conflicts []MetadataConflict
+}
+
+// PushMetadata: Publish the recorded relationships to origin under refs/stacksmith/stack.
+// Returns ErrMetadataDiverged when origin has metadata that has not been fetched and merged.
+func (g *GitExecutor) PushMetadata() error {
+	config, err := g.LoadStackConfig()
+	if err != nil {
+		return err
+	}
+
+	local, _ := g.resolveCommit(metadataRef)
+	remote, _ := g.resolveCommit(remoteMetadataRef)
+
+	// Origin's copy must already be part of ours, otherwise pushing would drop a teammate's changes
+	if remote != "" && (local == "" || !g.IsAncestor(remote, local)) {
+		return ErrMetadataDiverged
+	}
+
+	shared := &SharedStack{MainBranch: config.Metadata.MainBranch, Relationships: config.Relationships}
+	commit, err := g.commitMetadata(shared, local)
+	if err != nil {
+		return err
+	}
+
+	if _, err := g.Execute("update-ref", "-m", "stacksmith: update stack metadata", metadataRef, commit); err != nil {
+		return err
+	}
+
+	if _, err := g.Execute("push", "origin", metadataRef+":"+metadataRef); err != nil {
+		var gitErr *GitError
+		if errors.As(err, &gitErr) && (strings.Contains(gitErr.Stderr, "rejected") || strings.Contains(gitErr.Stderr, "non-fast-forward")) {
+			return ErrMetadataDiverged
+		}
+		return err
+	}
+
+	_, err = g.Execute("update-ref", "-m", "stacksmith: push stack metadata", remoteMetadataRef, commit)
+	return err
+}
+
+// FetchMetadata: Fetch origin's stack metadata and merge it into the recorded relationships.
+// A branch whose parent changed on both sides keeps the local parent and is reported as a
+// conflict, unless preferTheirs is set.
+func (g *GitExecutor) FetchMetadata(preferTheirs bool) (*MetadataMergeResult, error) {
+	result := &MetadataMergeResult{}
+
+	// Fetching a ref the remote does not have is an error, so check for it first
+	output, err := g.Execute("ls-remote", "origin", metadataRef)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(output) == "" {
+		return result, nil
+	}
+	result.Shared = true
+
+	if _, err := g.Execute("fetch", "origin", "+"+metadataRef+":"+remoteMetadataRef); err != nil {
+		return nil, err
+	}
+
+	remote, err := g.resolveCommit(remoteMetadataRef)
+	if err != nil {
+		if g.DryRun {
+			return result, nil // Nothing was fetched yet
+		}
+		return nil, err
+	}
+	theirs, err := g.readMetadata(remote)
+	if err != nil {
+		return nil, err
+	}
+
+	// The base is the metadata both sides last agreed on
+	base := &SharedStack{Relationships: make(map[string]string)}
+	local, _ := g.resolveCommit(metadataRef)
+	if local != "" {
+		if output, err := g.Execute("merge-base", local, remote); err == nil {
+			if base, err = g.readMetadata(strings.TrimSpace(output)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Merged under the stack lock, so local changes made since fetching aren't lost
+	var merged *SharedStack
+	err = g.UpdateStackConfig(func(config *StackConfig) error {
+		result.Updated, result.Conflicts = nil, nil
+		mergeMetadata(config, base, theirs, preferTheirs, result)
+		merged = &SharedStack{MainBranch: config.Metadata.MainBranch, Relationships: config.Relationships}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the merge so the next push fast-forwards origin's metadata
+	var parents []string
+	if local != "" && !g.IsAncestor(local, remote) {
+		parents = append(parents, local)
+	}
+	parents = append(parents, remote)
+
+	commit, err := g.commitMetadata(merged, parents...)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = g.Execute("update-ref", "-m", "stacksmith: merge stack metadata", metadataRef, commit)
+	return result, err
+}
+
+// mergeMetadata: Three-way merge theirs into config's relationships, from the base both sides
+// last agreed on, recording what changed and what conflicted in result
+func mergeMetadata(config *StackConfig, base, theirs *SharedStack, preferTheirs bool, result *MetadataMergeResult) {
+	branches := make(map[string]bool)
+	for _, relationships := range []map[string]string{base.Relationships, config.Relationships, theirs.Relationships} {
+		for branch := range relationships {
+			branches[branch] = true
+		}
+	}
+
+	for branch := range branches {
+		baseParent := base.Relationships[branch]
+		ourParent := config.Relationships[branch]
+		theirParent := theirs.Relationships[branch]
+
+		if ourParent == theirParent || theirParent == baseParent {
+			continue // Nothing new on the remote
+		}
+
+		if ourParent != baseParent {
+			result.Conflicts = append(result.Conflicts, MetadataConflict{Branch: branch, Ours: ourParent, Theirs: theirParent})
+			if !preferTheirs {
+				continue
+			}
+		}
+
+		// The fork point belonged to the old parent
+		delete(config.ForkPoints, branch)
+		if theirParent == "" {
+			delete(config.Relationships, branch)
+		} else {
+			config.Relationships[branch] = theirParent
+		}
+		result.Updated = append(result.Updated, branch)
+	}
+
+	sort.Strings(result.Updated)
+	sort.Slice(result.Conflicts, func(i, j int) bool {
+		return result.Conflicts[i].Branch < result.Conflicts[j].Branch
+	})
+
+	if config.Metadata.MainBranch == "" {
+		config.Metadata.MainBranch = theirs.MainBranch
+	}
+}
+
+// commitMetadata: Create a metadata commit on top of parents, reusing a lone parent whose content already matches
+func (g *GitExecutor) commitMetadata(shared *SharedStack, parents ...string) (string, error) {
+	data, err := yaml.Marshal(shared)
+	if err != nil {
+		return "", err
+	}
+
+	blob, err := g.ExecuteWithInput(nil, string(data), "hash-object", "-w", "--stdin")
+	if err != nil {
+		return "", err
+	}
+
+	tree, err := g.ExecuteWithInput(nil, fmt.Sprintf("100644 blob %s\t%s\n", strings.TrimSpace(blob), metadataFile), "mktree")
+	if err != nil {
+		return "", err
+	}
+	tree = strings.TrimSpace(tree)
+
+	var nonEmpty []string
+	for _, parent := range parents {
+		if parent != "" {
+			nonEmpty = append(nonEmpty, parent)
+		}
+	}
+
+	// Avoid empty commits when nothing changed since the last push
+	if len(nonEmpty) == 1 {
+		if parentTree, err := g.resolveTree(nonEmpty[0]); err == nil && parentTree == tree {
+			return nonEmpty[0], nil
+		}
+	}
+
+	args := []string{"commit-tree", tree}
+	for _, parent := range nonEmpty {
+		args = append(args, "-p", parent)
+	}
+	args = append(args, "-m", "stacksmith: update stack metadata")
+
+	commit, err := g.Execute(args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(commit), nil
+}
+
+// readMetadata: Read the shared stack stored in a metadata commit
+func (g *GitExecutor) readMetadata(commit string) (*SharedStack, error) {
+	output, err := g.Execute("cat-file", "blob", commit+":"+metadataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared SharedStack
+	if err := yaml.Unmarshal([]byte(output), &shared); err != nil {
+		return nil, err
+	}
+	if shared.Relationships == nil {
+		shared.Relationships = make(map[string]string)
+	}
+	return &shared, nil
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeMetadata(t *testing.T) {
+	tests := []struct {
+		name          string
+		base          map[string]string
+		ours          map[string]string
+		theirs        map[string]string
+		preferTheirs  bool
+		want          map[string]string
+		wantUpdated   []string
+		wantConflicts []MetadataConflict
+	}{
+		{
+			name:        "branch added on the remote",
+			base:        map[string]string{"a": "main"},
+			ours:        map[string]string{"a": "main"},
+			theirs:      map[string]string{"a": "main", "b": "a"},
+			want:        map[string]string{"a": "main", "b": "a"},
+			wantUpdated: []string{"b"},
+		},
+		{
+			name:   "branch added here",
+			base:   map[string]string{"a": "main"},
+			ours:   map[string]string{"a": "main", "b": "a"},
+			theirs: map[string]string{"a": "main"},
+			want:   map[string]string{"a": "main", "b": "a"},
+		},
+		{
+			name:        "parent changed on the remote",
+			base:        map[string]string{"a": "main", "b": "a"},
+			ours:        map[string]string{"a": "main", "b": "a"},
+			theirs:      map[string]string{"a": "main", "b": "main"},
+			want:        map[string]string{"a": "main", "b": "main"},
+			wantUpdated: []string{"b"},
+		},
+		{
+			name:        "branch removed on the remote",
+			base:        map[string]string{"a": "main", "b": "a"},
+			ours:        map[string]string{"a": "main", "b": "a"},
+			theirs:      map[string]string{"a": "main"},
+			want:        map[string]string{"a": "main"},
+			wantUpdated: []string{"b"},
+		},
+		{
+			name:   "branch removed here",
+			base:   map[string]string{"a": "main", "b": "a"},
+			ours:   map[string]string{"a": "main"},
+			theirs: map[string]string{"a": "main", "b": "a"},
+			want:   map[string]string{"a": "main"},
+		},
+		{
+			name:   "same change on both sides",
+			base:   map[string]string{"b": "a"},
+			ours:   map[string]string{"b": "main"},
+			theirs: map[string]string{"b": "main"},
+			want:   map[string]string{"b": "main"},
+		},
+		{
+			name:          "different changes keep ours",
+			base:          map[string]string{"b": "a"},
+			ours:          map[string]string{"b": "main"},
+			theirs:        map[string]string{"b": "c"},
+			want:          map[string]string{"b": "main"},
+			wantConflicts: []MetadataConflict{{Branch: "b", Ours: "main", Theirs: "c"}},
+		},
+		{
+			name:          "different changes take theirs when preferred",
+			base:          map[string]string{"b": "a"},
+			ours:          map[string]string{"b": "main"},
+			theirs:        map[string]string{"b": "c"},
+			preferTheirs:  true,
+			want:          map[string]string{"b": "c"},
+			wantUpdated:   []string{"b"},
+			wantConflicts: []MetadataConflict{{Branch: "b", Ours: "main", Theirs: "c"}},
+		},
+		{
+			name:          "removed here and changed on the remote",
+			base:          map[string]string{"b": "a"},
+			ours:          map[string]string{},
+			theirs:        map[string]string{"b": "main"},
+			want:          map[string]string{},
+			wantConflicts: []MetadataConflict{{Branch: "b", Ours: "", Theirs: "main"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &StackConfig{Relationships: tt.ours, ForkPoints: make(map[string]string)}
+			for branch := range tt.ours {
+				config.ForkPoints[branch] = "fork-" + branch
+			}
+			result := &MetadataMergeResult{}
+
+			mergeMetadata(config, &SharedStack{Relationships: tt.base}, &SharedStack{MainBranch: "main", Relationships: tt.theirs}, tt.preferTheirs, result)
+
+			if !reflect.DeepEqual(config.Relationships, tt.want) {
+				t.Errorf("relationships: got %v, want %v", config.Relationships, tt.want)
+			}
+			if !reflect.DeepEqual(result.Updated, tt.wantUpdated) {
+				t.Errorf("updated: got %v, want %v", result.Updated, tt.wantUpdated)
+			}
+			if !reflect.DeepEqual(result.Conflicts, tt.wantConflicts) {
+				t.Errorf("conflicts: got %+v, want %+v", result.Conflicts, tt.wantConflicts)
+			}
+
+			// A branch given a new parent loses the fork point it had against the old one
+			for _, branch := range tt.wantUpdated {
+				if _, kept := config.ForkPoints[branch]; kept {
+					t.Errorf("%s kept its fork point", branch)
+				}
+			}
+			if config.Metadata.MainBranch != "main" {
+				t.Errorf("main branch: got %q, want the remote's", config.Metadata.MainBranch)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package core
+
+import "sort"
+
+// ChildrenOf: Return the names of a branch's children, sorted
+func (s *BranchStack) ChildrenOf(branch string) []string {
+	node := s.AllNodes[branch]
+	if node == nil {
+		return nil
+	}
+
+	var children []string
+	for _, child := range node.Children {
+		children = append(children, child.Name)
+	}
+	sort.Strings(children)
+	return children
+}
+
+// ParentOf: Return the name of a branch's parent, or "" if it has none
+func (s *BranchStack) ParentOf(branch string) string {
+	node := s.AllNodes[branch]
+	if node == nil || node.Parent == nil {
+		return ""
+	}
+	return node.Parent.Name
+}
+
+// BottomOf: Return the first branch above trunk in the stack containing branch, or the root
+// of a stack that doesn't start on trunk. Returns "" for trunk and unknown branches
+func (s *BranchStack) BottomOf(branch string) string {
+	node := s.AllNodes[branch]
+	if node == nil || node.Name == s.MainBranch {
+		return ""
+	}
+
+	visited := map[string]bool{node.Name: true}
+	for node.Parent != nil && node.Parent.Name != s.MainBranch && !visited[node.Parent.Name] {
+		node = node.Parent
+		visited[node.Name] = true
+	}
+	return node.Name
+}
@@ -0,0 +1,38 @@
+package core
+
+import "testing"
+
+func TestBottomOf(t *testing.T) {
+	stack := stackFrom(map[string]string{
+		"main": "",
+		"a":    "main",
+		"b":    "a",
+		"c":    "b",
+		"lone": "",
+		"d":    "lone",
+		"e":    "d",
+		"x":    "y",
+		"y":    "x",
+	})
+
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{branch: "main", want: ""},
+		{branch: "missing", want: ""},
+		{branch: "a", want: "a"},
+		{branch: "c", want: "a"},
+		{branch: "lone", want: "lone"},
+		{branch: "e", want: "lone"},
+		{branch: "x", want: "y"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			if got := stack.BottomOf(tt.branch); got != tt.want {
+				t.Errorf("BottomOf(%s) = %q, want %q", tt.branch, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,495 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxSnapshots is the number of operations kept in the operation log
+const maxSnapshots = 50
+
+// BranchSnapshot records where a branch and its upstream pointed
+type BranchSnapshot struct {
+	SHA         string `yaml:"sha"`
+	Upstream    string `yaml:"upstream,omitempty"`
+	UpstreamSHA string `yaml:"upstream_sha,omitempty"`
+}
+
+// OperationSnapshot captures every local branch and the stack metadata before a mutating command
+type OperationSnapshot struct {
+	ID         string                    `yaml:"id"`
+	Command    string                    `yaml:"command"`
+	CreatedAt  time.Time                 `yaml:"created_at"`
+	HeadBranch string                    `yaml:"head_branch"`
+	Branches   map[string]BranchSnapshot `yaml:"branches"`
+	Stack      *StackConfig              `yaml:"stack"`
+	After      map[string]string         `yaml:"after,omitempty"` // Branch tips once the command finished
+}
+
+// UndoResult describes what restoring a snapshot changed
+type UndoResult struct {
+	Restored      []string // Branches moved back to their snapshot commit
+	Deleted       []string // Branches created after the snapshot
+	Skipped       []string // Branches changed after the operation, left as they are
+	RemoteChanged []string // Branches whose remote no longer matches the snapshot
+}
+
+// oplogDir: Return the directory holding the operation log
+func (g *GitExecutor) oplogDir() (string, error) {
+	stacksmithDir, err := g.stacksmithDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stacksmithDir, "oplog"), nil
+}
+
+// SnapshotOperation records the current state of every branch before a command changes it
+func (g *GitExecutor) SnapshotOperation(command string) (*OperationSnapshot, error) {
+	branches, err := g.snapshotBranches()
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := g.LoadStackConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	headBranch, err := g.GetCurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	snapshot := &OperationSnapshot{
+		ID:         now.Format("20060102-150405.000"),
+		Command:    command,
+		CreatedAt:  now,
+		HeadBranch: headBranch,
+		Branches:   branches,
+		Stack:      config,
+	}
+
+	// Nothing is changed during a dry run, so there is nothing to undo
+	if g.DryRun {
+		return snapshot, nil
+	}
+
+	if err := g.writeSnapshot(snapshot); err != nil {
+		return nil, err
+	}
+
+	return snapshot, g.pruneSnapshots()
+}
+
+// writeSnapshot: Save an entry of the operation log
+func (g *GitExecutor) writeSnapshot(snapshot *OperationSnapshot) error {
+	dir, err := g.oplogDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	yamlData, err := yaml.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, snapshot.ID+".yml"), yamlData, 0644)
+}
+
+// FinishOperation records where every branch ended up after the latest operation, so that
+// undoing it can tell the operation's changes from changes made afterwards
+func (g *GitExecutor) FinishOperation() error {
+	if g.DryRun {
+		return nil
+	}
+
+	snapshots, err := g.ListSnapshots()
+	if err != nil || len(snapshots) == 0 {
+		return err
+	}
+
+	branches, err := g.snapshotBranches()
+	if err != nil {
+		return err
+	}
+
+	latest := snapshots[len(snapshots)-1]
+	latest.After = make(map[string]string)
+	for name, branch := range branches {
+		latest.After[name] = branch.SHA
+	}
+	return g.writeSnapshot(latest)
+}
+
+// snapshotBranches: Return the tip and upstream of every local branch
+func (g *GitExecutor) snapshotBranches() (map[string]BranchSnapshot, error) {
+	output, err := g.Execute("for-each-ref", "--format=%(refname:short) %(objectname) %(upstream:short)", "refs/heads/")
+	if err != nil {
+		return nil, err
+	}
+
+	remoteSHAs, err := g.remoteBranchSHAs()
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make(map[string]BranchSnapshot)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			continue
+		}
+
+		snapshot := BranchSnapshot{SHA: parts[1]}
+		if len(parts) > 2 {
+			snapshot.Upstream = parts[2]
+			snapshot.UpstreamSHA = remoteSHAs[parts[2]]
+		}
+		branches[parts[0]] = snapshot
+	}
+
+	return branches, nil
+}
+
+// remoteBranchSHAs: Return the commit of every remote-tracking branch
+func (g *GitExecutor) remoteBranchSHAs() (map[string]string, error) {
+	output, err := g.Execute("for-each-ref", "--format=%(refname:short) %(objectname)", "refs/remotes/")
+	if err != nil {
+		return nil, err
+	}
+
+	shas := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		parts := strings.Fields(line)
+		if len(parts) == 2 {
+			shas[parts[0]] = parts[1]
+		}
+	}
+	return shas, nil
+}
+
+// ListSnapshots returns the operation log, oldest first
+func (g *GitExecutor) ListSnapshots() ([]*OperationSnapshot, error) {
+	dir, err := g.oplogDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []*OperationSnapshot
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yml") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var snapshot OperationSnapshot
+		if err := yaml.Unmarshal(data, &snapshot); err != nil {
+			return nil, fmt.Errorf("corrupt operation log entry %s: %w", entry.Name(), err)
+		}
+		snapshots = append(snapshots, &snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID < snapshots[j].ID
+	})
+	return snapshots, nil
+}
+
+// pruneSnapshots: Drop the oldest operations beyond maxSnapshots
+func (g *GitExecutor) pruneSnapshots() error {
+	snapshots, err := g.ListSnapshots()
+	if err != nil || len(snapshots) <= maxSnapshots {
+		return err
+	}
+	return g.removeSnapshots(snapshots[:len(snapshots)-maxSnapshots])
+}
+
+// removeSnapshots: Delete entries from the operation log
+func (g *GitExecutor) removeSnapshots(snapshots []*OperationSnapshot) error {
+	if g.DryRun {
+		return nil
+	}
+
+	dir, err := g.oplogDir()
+	if err != nil {
+		return err
+	}
+
+	for _, snapshot := range snapshots {
+		if err := os.Remove(filepath.Join(dir, snapshot.ID+".yml")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChangedSinceOperation returns the branches that undoing the given operation would reset or
+// delete, but that changed after the latest operation finished, by hand or by another tool
+func (g *GitExecutor) ChangedSinceOperation(id string) ([]string, error) {
+	snapshots, index, err := g.findSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+	return g.changedSince(snapshots[index], snapshots[len(snapshots)-1])
+}
+
+// UndoOperation restores branches and stack metadata to the state before the given operation,
+// dropping it and every later operation from the log. Branches that changed after the latest
+// operation finished are left alone unless includeChanged is set.
+func (g *GitExecutor) UndoOperation(id string, includeChanged bool) (*OperationSnapshot, *UndoResult, error) {
+	snapshots, index, err := g.findSnapshot(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshot := snapshots[index]
+
+	// Resetting branches under an interrupted operation would leave its journal pointing at the wrong commits
+	journal, err := g.LoadJournal()
+	if err != nil {
+		return nil, nil, err
+	}
+	if journal != nil {
+		return nil, nil, fmt.Errorf("an interrupted %s is in progress; continue or abort it before undoing", journal.Command)
+	}
+
+	rebasing, err := g.IsRebaseInProgress()
+	if err != nil {
+		return nil, nil, err
+	}
+	if rebasing {
+		return nil, nil, fmt.Errorf("a rebase is in progress; finish or abort it before undoing")
+	}
+
+	dirty, err := g.IsWorkingTreeDirty()
+	if err != nil {
+		return nil, nil, err
+	}
+	if dirty {
+		return nil, nil, fmt.Errorf("working tree has uncommitted changes; commit or stash them before undoing")
+	}
+
+	skip := make(map[string]bool)
+	if !includeChanged {
+		changed, err := g.changedSince(snapshot, snapshots[len(snapshots)-1])
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, name := range changed {
+			skip[name] = true
+		}
+	}
+
+	result, err := g.restoreSnapshot(snapshot, skip)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return snapshot, result, g.removeSnapshots(snapshots[index:])
+}
+
+// findSnapshot: Return the operation log and the position of the operation with the given id
+func (g *GitExecutor) findSnapshot(id string) ([]*OperationSnapshot, int, error) {
+	snapshots, err := g.ListSnapshots()
+	if err != nil {
+		return nil, -1, err
+	}
+
+	for i, snapshot := range snapshots {
+		if snapshot.ID == id {
+			return snapshots, i, nil
+		}
+	}
+	return nil, -1, fmt.Errorf("no operation with id %s", id)
+}
+
+// changedSince: Return the branches restoring snapshot would reset or delete whose tips no
+// longer match where latest left them. Without a record of where latest left them, every
+// such branch is treated as changed.
+func (g *GitExecutor) changedSince(snapshot, latest *OperationSnapshot) ([]string, error) {
+	current, err := g.snapshotBranches()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for name := range current {
+		names[name] = true
+	}
+	for name := range snapshot.Branches {
+		names[name] = true
+	}
+
+	var changed []string
+	for name := range names {
+		now, exists := current[name]
+		want, existed := snapshot.Branches[name]
+		if exists == existed && now.SHA == want.SHA {
+			continue // Nothing to restore
+		}
+
+		after, existedAfter := latest.After[name]
+		if latest.After == nil || exists != existedAfter || now.SHA != after {
+			changed = append(changed, name)
+		}
+	}
+
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// restoreSnapshot: Move every branch back to where the snapshot recorded it, except the skipped ones
+func (g *GitExecutor) restoreSnapshot(snapshot *OperationSnapshot, skip map[string]bool) (*UndoResult, error) {
+	current, err := g.snapshotBranches()
+	if err != nil {
+		return nil, err
+	}
+
+	currentBranch, err := g.GetCurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UndoResult{}
+
+	// Step off a branch that did not exist yet so it can be deleted
+	if _, existed := snapshot.Branches[currentBranch]; !existed && !skip[currentBranch] {
+		if err := g.CheckoutBranch(snapshot.HeadBranch); err != nil {
+			return nil, err
+		}
+		currentBranch = snapshot.HeadBranch
+	}
+
+	names := make([]string, 0, len(snapshot.Branches))
+	for name := range snapshot.Branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		want := snapshot.Branches[name]
+		have, exists := current[name]
+
+		if skip[name] {
+			result.Skipped = append(result.Skipped, name)
+			continue
+		}
+
+		if !exists || have.SHA != want.SHA {
+			if err := g.ResetBranch(name, want.SHA); err != nil {
+				return nil, err
+			}
+			result.Restored = append(result.Restored, name)
+		}
+
+		if want.Upstream != "" && (!exists || have.Upstream != want.Upstream) {
+			if _, err := g.Execute("branch", "--set-upstream-to="+want.Upstream, name); err != nil {
+				return nil, err
+			}
+		}
+
+		if exists && want.Upstream != "" && have.UpstreamSHA != want.UpstreamSHA {
+			result.RemoteChanged = append(result.RemoteChanged, name)
+		}
+	}
+
+	for name := range current {
+		if _, existed := snapshot.Branches[name]; existed {
+			continue
+		}
+		if skip[name] {
+			result.Skipped = append(result.Skipped, name)
+			continue
+		}
+		if err := g.DeleteBranch(name); err != nil {
+			return nil, err
+		}
+		result.Deleted = append(result.Deleted, name)
+	}
+	sort.Strings(result.Deleted)
+	sort.Strings(result.Skipped)
+
+	if currentBranch != snapshot.HeadBranch && !skip[snapshot.HeadBranch] {
+		if err := g.CheckoutBranch(snapshot.HeadBranch); err != nil {
+			return nil, err
+		}
+	}
+
+	// Skipped branches keep where they are recorded as well
+	if snapshot.Stack != nil {
+		err := g.UpdateStackConfig(func(config *StackConfig) error {
+			restoreStackEntries(config, snapshot.Stack, skip)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// restoreStackEntries: Put back the recorded parent and fork point of every branch that isn't
+// skipped, and the named stacks rooted at them, as saved had them
+func restoreStackEntries(config, saved *StackConfig, skip map[string]bool) {
+	restoreEntries(config.Relationships, saved.Relationships, skip)
+	restoreEntries(config.ForkPoints, saved.ForkPoints, skip)
+
+	names := make(map[string]bool)
+	for name := range config.Stacks {
+		names[name] = true
+	}
+	for name := range saved.Stacks {
+		names[name] = true
+	}
+	for name := range names {
+		current, exists := config.Stacks[name]
+		want, existed := saved.Stacks[name]
+		if (exists && skip[current.Root]) || (existed && skip[want.Root]) {
+			continue
+		}
+		if existed {
+			config.Stacks[name] = want
+		} else {
+			delete(config.Stacks, name)
+		}
+	}
+
+	if saved.Metadata.MainBranch != "" {
+		config.Metadata.MainBranch = saved.Metadata.MainBranch
+	}
+}
+
+// restoreEntries: Set every branch's entry in current back to saved's, except the skipped ones
+func restoreEntries(current, saved map[string]string, skip map[string]bool) {
+	for branch := range current {
+		if _, existed := saved[branch]; !existed && !skip[branch] {
+			delete(current, branch)
+		}
+	}
+	for branch, value := range saved {
+		if !skip[branch] {
+			current[branch] = value
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUndoOperationSkipsBranchesChangedSince(t *testing.T) {
+	tests := []struct {
+		name           string
+		finished       bool // FinishOperation ran after the operation
+		changedByHand  bool // b moved after the operation finished
+		includeChanged bool
+		wantChanged    []string
+		wantResult     UndoResult
+		wantParents    map[string]string
+	}{
+		{
+			name:          "branch changed after the operation is left alone",
+			finished:      true,
+			changedByHand: true,
+			wantChanged:   []string{"b"},
+			wantResult:    UndoResult{Restored: []string{"a"}, Deleted: []string{"c"}, Skipped: []string{"b"}},
+			wantParents:   map[string]string{"a": "main", "b": "main"},
+		},
+		{
+			name:           "branch changed after the operation is reset when asked",
+			finished:       true,
+			changedByHand:  true,
+			includeChanged: true,
+			wantChanged:    []string{"b"},
+			wantResult:     UndoResult{Restored: []string{"a", "b"}, Deleted: []string{"c"}},
+			wantParents:    map[string]string{"a": "main", "b": "a"},
+		},
+		{
+			name:        "nothing changed since",
+			finished:    true,
+			wantResult:  UndoResult{Restored: []string{"a", "b"}, Deleted: []string{"c"}},
+			wantParents: map[string]string{"a": "main", "b": "a"},
+		},
+		{
+			name:        "operation never finished",
+			wantChanged: []string{"a", "b", "c"},
+			wantResult:  UndoResult{Skipped: []string{"a", "b", "c"}},
+			wantParents: map[string]string{"a": "main", "b": "main", "c": "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := fixtureRepo(t, [][]string{
+				commit("m1"),
+				checkout("a", true), commit("a1"),
+				checkout("b", true), commit("b1"),
+				checkout("main", false),
+			})
+			recordParents(t, g, map[string]string{"a": "main", "b": "a"})
+
+			snapshot, err := g.SnapshotOperation("test")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The operation moves a and b, creates c and records b and c elsewhere
+			run(t, g,
+				checkout("a", false), commit("a2"),
+				checkout("b", false), commit("b2"),
+				checkout("c", true), commit("c1"),
+				checkout("main", false),
+			)
+			recordParents(t, g, map[string]string{"b": "main", "c": "b"})
+			if tt.finished {
+				if err := g.FinishOperation(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var handSHA string
+			if tt.changedByHand {
+				run(t, g, checkout("b", false), commit("b3"), checkout("main", false))
+				handSHA = branchSHA(t, g, "b")
+			}
+
+			changed, err := g.ChangedSinceOperation(snapshot.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(changed, tt.wantChanged) {
+				t.Errorf("changed since: got %v, want %v", changed, tt.wantChanged)
+			}
+
+			_, result, err := g.UndoOperation(snapshot.ID, tt.includeChanged)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result.RemoteChanged = nil
+			if !reflect.DeepEqual(*result, tt.wantResult) {
+				t.Errorf("result: got %+v, want %+v", *result, tt.wantResult)
+			}
+
+			for _, name := range tt.wantResult.Restored {
+				if got := branchSHA(t, g, name); got != snapshot.Branches[name].SHA {
+					t.Errorf("%s is at %s, want %s", name, got, snapshot.Branches[name].SHA)
+				}
+			}
+			if tt.changedByHand && !tt.includeChanged {
+				if got := branchSHA(t, g, "b"); got != handSHA {
+					t.Errorf("b is at %s, want the hand-made commit %s", got, handSHA)
+				}
+			}
+
+			config, err := g.LoadStackConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(config.Relationships, tt.wantParents) {
+				t.Errorf("relationships: got %v, want %v", config.Relationships, tt.wantParents)
+			}
+		})
+	}
+}
+
+func TestUndoOperationRefusesDuringInterruptedOperation(t *testing.T) {
+	g := fixtureRepo(t, [][]string{commit("m1"), checkout("a", true), commit("a1"), checkout("main", false)})
+
+	snapshot, err := g.SnapshotOperation("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SaveJournal(NewOperationJournal("sync", "main", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := g.UndoOperation(snapshot.ID, false); err == nil {
+		t.Fatal("expected undo to be refused while a sync is interrupted")
+	}
+}
+
+func TestRestoreStackEntries(t *testing.T) {
+	saved := &StackConfig{
+		Relationships: map[string]string{"a": "main", "b": "a", "gone": "main"},
+		ForkPoints:    map[string]string{"a": "m1", "b": "a1"},
+		Stacks:        map[string]*NamedStack{"payments": {Root: "a"}, "old": {Root: "gone"}},
+	}
+	config := &StackConfig{
+		Relationships: map[string]string{"a": "main", "b": "main", "new": "b"},
+		ForkPoints:    map[string]string{"a": "m2", "b": "m2", "new": "b2"},
+		Stacks:        map[string]*NamedStack{"payments": {Root: "b"}, "fresh": {Root: "new"}},
+	}
+	config.Metadata.MainBranch = "main"
+
+	restoreStackEntries(config, saved, map[string]bool{"b": true})
+
+	wantParents := map[string]string{"a": "main", "b": "main", "gone": "main"}
+	if !reflect.DeepEqual(config.Relationships, wantParents) {
+		t.Errorf("relationships: got %v, want %v", config.Relationships, wantParents)
+	}
+	wantForkPoints := map[string]string{"a": "m1", "b": "m2"}
+	if !reflect.DeepEqual(config.ForkPoints, wantForkPoints) {
+		t.Errorf("fork points: got %v, want %v", config.ForkPoints, wantForkPoints)
+	}
+
+	// payments is rooted at the skipped b now, so it stays as it is
+	if config.Stacks["payments"].Root != "b" || config.Stacks["old"] == nil || config.Stacks["fresh"] != nil {
+		t.Errorf("named stacks: got %v", config.Stacks)
+	}
+}
+
+// recordParents records each branch's parent in the stack config
+func recordParents(t *testing.T, g *GitExecutor, parents map[string]string) {
+	t.Helper()
+	for child, parent := range parents {
+		if err := g.RecordBranchRelationship(child, parent); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// branchSHA returns the commit a branch points at
+func branchSHA(t *testing.T, g *GitExecutor, branch string) string {
+	t.Helper()
+	sha, err := g.GetBranchSHA(branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sha
+}
@@ -0,0 +1,106 @@
+package core
+
+import "fmt"
+
+// PushCandidate is a local branch whose tip differs from its remote-tracking ref
+type PushCandidate struct {
+	Branch    string
+	LocalSHA  string
+	RemoteSHA string // Empty when the branch has never been pushed
+}
+
+// ChangedBranches returns the branches whose local tip differs from origin's copy
+func (g *GitExecutor) ChangedBranches(branches []string) ([]PushCandidate, error) {
+	remoteSHAs, err := g.remoteBranchSHAs()
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []PushCandidate
+	for _, branch := range branches {
+		localSHA, err := g.GetBranchSHA(branch)
+		if err != nil {
+			return nil, err
+		}
+
+		remoteSHA := remoteSHAs["origin/"+branch]
+		if localSHA != remoteSHA {
+			changed = append(changed, PushCandidate{Branch: branch, LocalSHA: localSHA, RemoteSHA: remoteSHA})
+		}
+	}
+
+	return changed, nil
+}
+
+// PushBranchesAtomic pushes every candidate to origin in one all-or-nothing push. Each ref
+// is leased against the remote commit last fetched, so the push fails if someone else moved it since.
+func (g *GitExecutor) PushBranchesAtomic(candidates []PushCandidate) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	args := []string{"push", "--atomic", "--set-upstream"}
+	for _, candidate := range candidates {
+		// An empty expected value means the branch must not exist on the remote yet
+		args = append(args, fmt.Sprintf("--force-with-lease=%s:%s", candidate.Branch, candidate.RemoteSHA))
+	}
+
+	args = append(args, "origin")
+	for _, candidate := range candidates {
+		args = append(args, fmt.Sprintf("%s:refs/heads/%s", candidate.Branch, candidate.Branch))
+	}
+
+	_, err := g.Execute(args...)
+	return err
+}
+
+// PushChangedBranches atomically pushes whichever of the given branches changed,
+// returning the branches that were pushed
+func (g *GitExecutor) PushChangedBranches(branches []string) ([]string, error) {
+	changed, err := g.ChangedBranches(branches)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.PushBranchesAtomic(changed); err != nil {
+		return nil, err
+	}
+
+	var pushed []string
+	for _, candidate := range changed {
+		pushed = append(pushed, candidate.Branch)
+	}
+	return pushed, nil
+}
+
+// StackOf: Return the branches in the stack containing branch, from the first branch above
+// trunk through every descendant, parents before children. Trunk is in no stack of its own,
+// so it has none.
+func (s *BranchStack) StackOf(branch string) ([]string, error) {
+	node := s.AllNodes[branch]
+	if node == nil {
+		return nil, &BranchNotFoundError{BranchName: branch}
+	}
+	if branch == s.MainBranch {
+		return nil, nil
+	}
+
+	// Walk down to the first branch above trunk
+	bottom := node
+	visited := map[string]bool{bottom.Name: true}
+	for bottom.Parent != nil && bottom.Parent.Name != s.MainBranch && !visited[bottom.Parent.Name] {
+		bottom = bottom.Parent
+		visited[bottom.Name] = true
+	}
+
+	steps, err := s.DescendantSteps(bottom.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	branches := []string{bottom.Name}
+	for _, step := range steps {
+		branches = append(branches, step.Branch)
+	}
+	return branches, nil
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStackOf(t *testing.T) {
+	stack := stackFrom(map[string]string{
+		"main": "",
+		"a":    "main",
+		"b":    "a",
+		"c":    "a",
+		"d":    "b",
+		"e":    "main",
+		"lone": "",
+		"f":    "lone",
+	})
+
+	tests := []struct {
+		name    string
+		branch  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "from the bottom", branch: "a", want: []string{"a", "b", "d", "c"}},
+		{name: "from the middle", branch: "d", want: []string{"a", "b", "d", "c"}},
+		{name: "other stacks are left out", branch: "e", want: []string{"e"}},
+		{name: "stack not on trunk", branch: "f", want: []string{"lone", "f"}},
+		{name: "trunk", branch: "main"},
+		{name: "unknown branch", branch: "missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stack.StackOf(tt.branch)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StackOf(%s) = %v, want %v", tt.branch, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ErrInMemoryUnsupported is returned when a branch cannot be restacked without a checkout,
+// either because git is too old or because its history contains merge commits
+var ErrInMemoryUnsupported = errors.New("branch cannot be restacked in memory")
+
+// ErrBranchCheckedOut is returned when moving a branch's ref would leave a working tree out of date
+var ErrBranchCheckedOut = errors.New("branch is checked out")
+
+// RestackInMemory replays the commits of branch after upstream onto newBase using
+// `git merge-tree` and `git commit-tree`, then moves the branch with `git update-ref`.
+// The working tree is never touched. A MergeConflictError is returned if any commit
+// does not apply cleanly, in which case the branch is left unchanged.
+func (g *GitExecutor) RestackInMemory(branch, newBase, upstream string) error {
+	// merge-tree --merge-base arrived in git 2.40
+	if !g.SupportsVersion(2, 40) {
+		return ErrInMemoryUnsupported
+	}
+
+	// Updating the ref of a branch checked out in any worktree would leave its index and files stale
+	currentBranch, err := g.GetCurrentBranch()
+	if err != nil {
+		return err
+	}
+	if currentBranch == branch {
+		return ErrBranchCheckedOut
+	}
+	worktreeBranches, err := g.OtherWorktreeBranches()
+	if err != nil {
+		return err
+	}
+	if worktreeBranches[branch] != "" {
+		return ErrBranchCheckedOut
+	}
+
+	branchSHA, err := g.GetBranchSHA(branch)
+	if err != nil {
+		return err
+	}
+
+	tip, err := g.resolveCommit(newBase)
+	if err != nil {
+		return err
+	}
+
+	// List the branch's own commits, oldest first, with their parents
+	output, err := g.Execute("rev-list", "--reverse", "--parents", upstream+".."+branchSHA)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return ErrInMemoryUnsupported // Merge commits need a real rebase
+		}
+		commit, parent := fields[0], fields[1]
+
+		// Nothing below this commit moved, so keep it as is
+		if parent == tip {
+			tip = commit
+			continue
+		}
+
+		tip, err = g.replayCommit(commit, parent, tip)
+		if err != nil {
+			if errors.Is(err, errReplayConflict) {
+				return &MergeConflictError{Branch: branch, Target: newBase}
+			}
+			return err
+		}
+	}
+
+	if tip == branchSHA {
+		return nil // Already up to date
+	}
+
+	_, err = g.Execute("update-ref", "-m", "stacksmith: restack onto "+newBase, "refs/heads/"+branch, tip, branchSHA)
+	return err
+}
+
+// errReplayConflict signals that a commit could not be replayed cleanly
+var errReplayConflict = errors.New("replay conflict")
+
+// replayCommit creates a copy of commit on top of onto, as a cherry-pick would, and returns its SHA
+func (g *GitExecutor) replayCommit(commit, parent, onto string) (string, error) {
+	output, err := g.Execute("merge-tree", "--write-tree", "--merge-base="+parent, onto, commit)
+	if err != nil {
+		var gitErr *GitError
+		var exitErr *exec.ExitError
+		// merge-tree exits with 1 when the merge has conflicts
+		if errors.As(err, &gitErr) && errors.As(gitErr.Err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", errReplayConflict
+		}
+		return "", err
+	}
+	tree := strings.TrimSpace(strings.SplitN(output, "\n", 2)[0])
+
+	// Keep the original authorship and message, like a rebase does
+	info, err := g.Execute("log", "-1", "--date=raw", "--format=%an%x00%ae%x00%ad%x00%B", commit)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.SplitN(info, "\x00", 4)
+	if len(parts) != 4 {
+		return "", fmt.Errorf("unexpected commit format for %s", commit)
+	}
+
+	env := []string{
+		"GIT_AUTHOR_NAME=" + parts[0],
+		"GIT_AUTHOR_EMAIL=" + parts[1],
+		"GIT_AUTHOR_DATE=" + parts[2],
+	}
+	message := strings.TrimRight(parts[3], "\n") + "\n"
+
+	newCommit, err := g.ExecuteWithInput(env, message, "commit-tree", tree, "-p", onto, "-F", "-")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(newCommit), nil
+}
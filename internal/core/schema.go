@@ -0,0 +1,97 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StackSchemaVersion is the stack.yml layout this build reads and writes.
+// Bump it together with a new entry in schemaMigrations whenever StackConfig changes shape.
+const StackSchemaVersion = 3
+
+// schemaMigration upgrades the top-level mapping of a stack.yml document by one version, in
+// place. Documents are worked on as nodes, so that values such as SHAs keep their exact text.
+type schemaMigration func(doc *yaml.Node) error
+
+// schemaMigrations maps each version to the migration that upgrades it to the next one.
+// Files written before the schema was versioned are version 1.
+var schemaMigrations = map[int]schemaMigration{
+	1: bumpVersion, // Version 2 added fork_points
+	2: bumpVersion, // Version 3 added named stacks
+}
+
+// bumpVersion: Upgrade a file whose content is already valid in the next version, as when
+// that version only added optional sections, which load as empty
+func bumpVersion(doc *yaml.Node) error {
+	return nil
+}
+
+// migrateStackConfig: Upgrade raw stack.yml data to the current schema, returning the
+// upgraded data and the version it was written with
+func migrateStackConfig(data []byte) ([]byte, int, error) {
+	var file yaml.Node
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, 0, err
+	}
+	if file.Kind == 0 {
+		file = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	doc := file.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		return nil, 0, fmt.Errorf("stack.yml is not a mapping")
+	}
+
+	version := 1
+	versionNode := mappingValue(doc, "version")
+	if versionNode != nil {
+		if err := versionNode.Decode(&version); err != nil {
+			return nil, 0, fmt.Errorf("stack.yml has an invalid version %v", versionNode.Value)
+		}
+	}
+
+	if version > StackSchemaVersion {
+		return nil, version, &SchemaVersionError{Found: version, Supported: StackSchemaVersion}
+	}
+	if version == StackSchemaVersion {
+		return data, version, nil
+	}
+
+	for v := version; v < StackSchemaVersion; v++ {
+		migrate, exists := schemaMigrations[v]
+		if !exists {
+			return nil, version, fmt.Errorf("no migration from stack.yml version %d", v)
+		}
+		if err := migrate(doc); err != nil {
+			return nil, version, fmt.Errorf("migrating stack.yml from version %d: %w", v, err)
+		}
+	}
+
+	current := strconv.Itoa(StackSchemaVersion)
+	if versionNode != nil {
+		versionNode.SetString(current)
+		versionNode.Tag = "!!int"
+	} else {
+		doc.Content = append([]*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"},
+			{Kind: yaml.ScalarNode, Tag: "!!int", Value: current},
+		}, doc.Content...)
+	}
+
+	migrated, err := yaml.Marshal(&file)
+	if err != nil {
+		return nil, version, err
+	}
+	return migrated, version, nil
+}
+
+// mappingValue: Return the value of key in a mapping node, or nil if it isn't there
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileStoreMigratesOlderSchemas(t *testing.T) {
+	tests := []struct {
+		fixture        string
+		version        int
+		wantForkPoints map[string]string
+	}{
+		{
+			fixture: "stack_v1.yml",
+			version: 1,
+		},
+		{
+			fixture: "stack_v2.yml",
+			version: 2,
+			wantForkPoints: map[string]string{
+				"feature/api": "1111111111111111111111111111111111111111",
+				"feature/ui":  "2222222222222222222222222222222222222222",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, dryRun := range []bool{false, true} {
+			name := tt.fixture
+			if dryRun {
+				name += " dry run"
+			}
+			t.Run(name, func(t *testing.T) {
+				original, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				g := fixtureRepo(t, [][]string{commit("m1")})
+				g.DryRun = dryRun
+				store := &FileStore{git: g}
+				path, err := store.path()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, original, 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				config, err := store.Load()
+				if err != nil {
+					t.Fatal(err)
+				}
+				wantParents := map[string]string{"feature/api": "main", "feature/ui": "feature/api"}
+				if !reflect.DeepEqual(config.Relationships, wantParents) {
+					t.Errorf("relationships: got %v, want %v", config.Relationships, wantParents)
+				}
+				if len(config.ForkPoints) != len(tt.wantForkPoints) || (len(tt.wantForkPoints) > 0 && !reflect.DeepEqual(config.ForkPoints, tt.wantForkPoints)) {
+					t.Errorf("fork points: got %v, want %v", config.ForkPoints, tt.wantForkPoints)
+				}
+				if len(config.Stacks) != 0 || config.Metadata.MainBranch != "main" {
+					t.Errorf("loaded %+v", config)
+				}
+
+				backup := fmt.Sprintf("%s.v%d.bak", path, tt.version)
+				written, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// A dry run leaves the old file alone, and so doesn't back it up
+				if dryRun {
+					if !bytes.Equal(written, original) {
+						t.Error("dry run rewrote stack.yml")
+					}
+					if _, err := os.Stat(backup); !os.IsNotExist(err) {
+						t.Errorf("dry run wrote a backup: %v", err)
+					}
+					return
+				}
+
+				if backed, err := os.ReadFile(backup); err != nil || !bytes.Equal(backed, original) {
+					t.Fatalf("backup missing or different: %v", err)
+				}
+				if _, version, err := migrateStackConfig(written); err != nil || version != StackSchemaVersion {
+					t.Errorf("stack.yml is at version %d (%v), want %d", version, err, StackSchemaVersion)
+				}
+
+				// The file is current now, reading it again doesn't back it up again
+				if err := os.Remove(backup); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := store.Load(); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := os.Stat(backup); !os.IsNotExist(err) {
+					t.Errorf("second load wrote a backup: %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestMigrateStackConfigRejectsNewerSchemas(t *testing.T) {
+	_, _, err := migrateStackConfig([]byte("version: 99\nrelationships: {}\n"))
+	if _, ok := err.(*SchemaVersionError); !ok {
+		t.Fatalf("expected a SchemaVersionError, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Commit is a commit as shown to the user
+type Commit struct {
+	SHA     string
+	Subject string
+}
+
+// SplitPiece is one of the branches a branch is split into, ending at Commit
+type SplitPiece struct {
+	Name   string
+	Commit string
+}
+
+// BranchCommits: Return the commits a branch has on top of base, oldest first. History merged
+// in from elsewhere is left out, so that any commit listed can be the tip of a branch.
+func (g *GitExecutor) BranchCommits(branch, base string) ([]Commit, error) {
+	output, err := g.Execute("log", "--first-parent", "--reverse", "--format=%H %s", base+".."+branch)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
+			continue
+		}
+		sha, subject, _ := strings.Cut(line, " ")
+		commits = append(commits, Commit{SHA: sha, Subject: subject})
+	}
+	return commits, nil
+}
+
+// IsValidBranchName: Check whether git accepts name as a branch name
+func (g *GitExecutor) IsValidBranchName(name string) bool {
+	_, err := g.Execute("check-ref-format", "--branch", name)
+	return err == nil
+}
+
+// SplitBranch: Turn a branch into a chain of branches on top of parent, the first forking at
+// base. The last piece may keep the branch's name; otherwise the branch's children move onto
+// the last piece, the branch itself is left alone in git but dropped from the stack, and the
+// moved children are returned.
+func (g *GitExecutor) SplitBranch(branch, parent, base string, pieces []SplitPiece) ([]string, error) {
+	if len(pieces) < 2 {
+		return nil, fmt.Errorf("splitting %s needs at least two pieces", branch)
+	}
+	last := pieces[len(pieces)-1]
+
+	for _, piece := range pieces {
+		if piece.Name == branch {
+			if piece != last {
+				return nil, fmt.Errorf("only the last piece can keep the name %s", branch)
+			}
+			continue
+		}
+		if _, err := g.Execute("branch", piece.Name, piece.Commit); err != nil {
+			return nil, err
+		}
+	}
+
+	var children []string
+	err := g.UpdateStackConfig(func(config *StackConfig) error {
+		below, forkPoint := parent, base
+		for _, piece := range pieces {
+			config.Relationships[piece.Name] = below
+			config.ForkPoints[piece.Name] = forkPoint
+			below, forkPoint = piece.Name, piece.Commit
+		}
+
+		children = nil
+		if last.Name != branch {
+			for child, recorded := range config.Relationships {
+				if recorded == branch {
+					config.Relationships[child] = last.Name
+					children = append(children, child)
+				}
+			}
+			sort.Strings(children)
+
+			delete(config.Relationships, branch)
+			delete(config.ForkPoints, branch)
+		}
+
+		// The first piece is now the bottom of the branch's named stack
+		for _, stack := range config.Stacks {
+			if stack.Root == branch {
+				stack.Root = pieces[0].Name
+			}
+		}
+		return nil
+	})
+
+	return children, err
+}
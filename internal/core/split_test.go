@@ -0,0 +1,107 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSplitBranch(t *testing.T) {
+	tests := []struct {
+		name           string
+		pieces         []string // Name of each piece, ending at feature's commits f1, f2 and so on
+		wantErr        bool
+		wantChildren   []string
+		wantParents    map[string]string
+		wantForkPoints map[string]string // Commit names, base for trunk's tip
+	}{
+		{
+			name:           "last piece keeps the name",
+			pieces:         []string{"p1", "p2", "feature"},
+			wantParents:    map[string]string{"p1": "main", "p2": "p1", "feature": "p2", "child": "feature"},
+			wantForkPoints: map[string]string{"p1": "base", "p2": "f1", "feature": "f2"},
+		},
+		{
+			name:           "children move onto the last piece",
+			pieces:         []string{"p1", "p2", "p3"},
+			wantChildren:   []string{"child"},
+			wantParents:    map[string]string{"p1": "main", "p2": "p1", "p3": "p2", "child": "p3"},
+			wantForkPoints: map[string]string{"p1": "base", "p2": "f1", "p3": "f2"},
+		},
+		{
+			name:    "only one piece",
+			pieces:  []string{"feature"},
+			wantErr: true,
+		},
+		{
+			name:    "name kept by a piece other than the last",
+			pieces:  []string{"feature", "p2", "p3"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := fixtureRepo(t, [][]string{
+				commit("m1"),
+				checkout("feature", true), commit("f1"), commit("f2"), commit("f3"),
+				checkout("child", true), commit("c1"),
+				checkout("main", false),
+			})
+			recordParents(t, g, map[string]string{"feature": "main", "child": "feature"})
+			err := g.UpdateStackConfig(func(config *StackConfig) error {
+				config.Stacks["payments"] = &NamedStack{Root: "feature"}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tips := []string{"f1", "f2", "f3"}
+			commits := map[string]string{"base": branchSHA(t, g, "main")}
+			for i, name := range tips {
+				commits[name] = branchSHA(t, g, fmt.Sprintf("feature~%d", len(tips)-1-i))
+			}
+
+			var pieces []SplitPiece
+			for i, name := range tt.pieces {
+				pieces = append(pieces, SplitPiece{Name: name, Commit: commits[tips[i]]})
+			}
+
+			children, err := g.SplitBranch("feature", "main", commits["base"], pieces)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(children, tt.wantChildren) {
+				t.Errorf("moved children: got %v, want %v", children, tt.wantChildren)
+			}
+
+			config, err := g.LoadStackConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(config.Relationships, tt.wantParents) {
+				t.Errorf("relationships: got %v, want %v", config.Relationships, tt.wantParents)
+			}
+			for branch, name := range tt.wantForkPoints {
+				if config.ForkPoints[branch] != commits[name] {
+					t.Errorf("fork point of %s: got %s, want %s (%s)", branch, config.ForkPoints[branch], commits[name], name)
+				}
+			}
+			if root := config.Stacks["payments"].Root; root != "p1" {
+				t.Errorf("named stack root: got %s, want p1", root)
+			}
+			for i, piece := range pieces {
+				if sha := branchSHA(t, g, piece.Name); sha != piece.Commit {
+					t.Errorf("piece %d %s: got %s, want %s", i, piece.Name, sha, piece.Commit)
+				}
+			}
+		})
+	}
+}
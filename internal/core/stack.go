@@ -1,18 +1,21 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
-
-	"gopkg.in/yaml.v3"
 )
 
 // StackConfig represents the stored branch relationships
 type StackConfig struct {
-	Relationships map[string]string `yaml:"relationships"`
+	Version       int                    `yaml:"version"` // Schema version, see StackSchemaVersion
+	Relationships map[string]string      `yaml:"relationships"`
+	ForkPoints    map[string]string      `yaml:"fork_points,omitempty"` // Parent commit each child was last based on
+	Stacks        map[string]*NamedStack `yaml:"stacks,omitempty"`      // Named stacks, keyed by name
 	Metadata      struct {
 		MainBranch  string    `yaml:"main_branch"`
 		LastUpdated time.Time `yaml:"last_updated"`
@@ -25,11 +28,14 @@ type BranchNode struct {
 	CommitSHA string
 	IsHead    bool
 	IsOrphan  bool
+	Worktree  string // Path of another worktree that has the branch checked out
+	Parent    *BranchNode
 	Children  []*BranchNode
 
-	Ahead    int
-	Behind   int
-	IsMerged bool
+	Ahead           int
+	Behind          int
+	IsMerged        bool
+	MergedIntoTrunk bool // Changes have landed in trunk, possibly via squash or rebase merge
 }
 
 // BranchStack represents the stack of branches
@@ -38,109 +44,184 @@ type BranchStack struct {
 	AllNodes   map[string]*BranchNode
 	MainBranch string // main or master
 	Orphans    []*BranchNode
+	Named      map[string]*NamedStack // Named stacks, keyed by their root branch
+	Suggested  []*ParentGuess         // Parents guessed with too little confidence to record
 }
 
-// BranchInfo hold details about the branch (intermediary date store)
-type BranchInfo struct {
-	Name      string
-	CommitSHA string
-	ParentSHA string
+// RestackStep describes rebasing a single branch onto its parent
+type RestackStep struct {
+	Branch   string `yaml:"branch"`
+	Parent   string `yaml:"parent"`
+	Upstream string `yaml:"upstream,omitempty"` // Commit the branch was based on before the restack
+	Worktree string `yaml:"worktree,omitempty"` // Another worktree the branch is checked out in
 }
 
-// SaveStackConfig: Save the stack config to a file
+// SaveStackConfig: Save the stack config to the configured store
 func (g *GitExecutor) SaveStackConfig(config *StackConfig) error {
-	// Get project root directory
-	rootDir, err := g.Execute("rev-parse", "--show-toplevel")
-	if err != nil {
-		return err
+	if g.DryRun {
+		g.recordPlannedWrite("Update stack relationships")
+		return nil
 	}
-	rootDir = strings.TrimSpace(rootDir)
 
-	// Create .git/stacksmith directory if it doesn't exist
-	stacksmithDir := filepath.Join(rootDir, ".git", "stacksmith")
-	if err := os.MkdirAll(stacksmithDir, 0755); err != nil {
-		return err
-	}
-
-	// Set last updated time
+	// Set last updated time and the schema the config is written with
 	config.Metadata.LastUpdated = time.Now()
+	config.Version = StackSchemaVersion
 
-	// Add header comment and marshal to YAML
-	yamlData, err := yaml.Marshal(config)
-	if err != nil {
-		return err
+	return g.stackStore().Save(config)
+}
+
+// UpdateStackConfig: Apply update to the latest stack config and save it as one
+// read-modify-write, so concurrent stacksmith processes don't lose each other's changes
+func (g *GitExecutor) UpdateStackConfig(update func(config *StackConfig) error) error {
+	if g.DryRun {
+		config, err := g.LoadStackConfig()
+		if err != nil {
+			return err
+		}
+		if err := update(config); err != nil {
+			if errors.Is(err, errStackUnchanged) {
+				return nil
+			}
+			return err
+		}
+		g.recordPlannedWrite("Update stack relationships")
+		return nil
 	}
 
-	// Add header comment
-	header := "# Stacksmith branch relationships\n" +
-		fmt.Sprintf("# Last updated: %s\n\n", config.Metadata.LastUpdated.Format("2006-01-02 15:04:05"))
+	return g.stackStore().Update(func(config *StackConfig) error {
+		g.prepareStackConfig(config)
+		if err := update(config); err != nil {
+			return err
+		}
 
-	// Write to file
-	filePath := filepath.Join(stacksmithDir, "stack.yml")
-	return os.WriteFile(filePath, []byte(header+string(yamlData)), 0644)
+		config.Metadata.LastUpdated = time.Now()
+		config.Version = StackSchemaVersion
+		return nil
+	})
 }
 
-// LoadStackConfig loads branch relationships from YAML
+// LoadStackConfig loads branch relationships from the configured store
 func (g *GitExecutor) LoadStackConfig() (*StackConfig, error) {
-	// Get project root directory
-	rootDir, err := g.Execute("rev-parse", "--show-toplevel")
+	config, err := g.stackStore().Load()
 	if err != nil {
 		return nil, err
 	}
-	rootDir = strings.TrimSpace(rootDir)
 
-	// Construct file path
-	filePath := filepath.Join(rootDir, ".git", "stacksmith", "stack.yml")
+	g.prepareStackConfig(config)
+	return config, nil
+}
 
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		// Return empty config if file doesn't exist
-		config := &StackConfig{
-			Relationships: make(map[string]string),
-		}
+// prepareStackConfig: Fill in what a freshly loaded config may be missing
+func (g *GitExecutor) prepareStackConfig(config *StackConfig) {
+	// Initialize if nil
+	if config.Relationships == nil {
+		config.Relationships = make(map[string]string)
+	}
+	if config.ForkPoints == nil {
+		config.ForkPoints = make(map[string]string)
+	}
+	if config.Stacks == nil {
+		config.Stacks = make(map[string]*NamedStack)
+	}
+	for name, stack := range config.Stacks {
+		stack.Name = name
+	}
 
-		// Determine main branch
+	// Determine main branch
+	if config.Metadata.MainBranch == "" {
 		for _, branch := range []string{"main", "master"} {
 			if _, err := g.Execute("rev-parse", "--verify", branch); err == nil {
 				config.Metadata.MainBranch = branch
 				break
 			}
 		}
-
-		return config, nil
 	}
+}
+
+// RecordBranchRelationship: Record a branch relationship in the stack config
+func (g *GitExecutor) RecordBranchRelationship(childBranch, parentBranch string) error {
+	// The child is based on the parent's current tip
+	parentSHA, shaErr := g.resolveCommit(parentBranch)
+
+	return g.UpdateStackConfig(func(config *StackConfig) error {
+		// Add or update relationship
+		config.Relationships[childBranch] = parentBranch
+		if shaErr == nil {
+			config.ForkPoints[childBranch] = parentSHA
+		}
+		return nil
+	})
+}
 
-	// Read file
-	data, err := os.ReadFile(filePath)
+// UpdateForkPoint: Record that a child is now based on its parent's current tip
+func (g *GitExecutor) UpdateForkPoint(childBranch, parentBranch string) error {
+	parentSHA, err := g.resolveCommit(parentBranch)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// Unmarshal YAML
-	var config StackConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, err
-	}
+	return g.UpdateStackConfig(func(config *StackConfig) error {
+		// Only track fork points against the recorded parent
+		if recorded, exists := config.Relationships[childBranch]; !exists || recorded != parentBranch {
+			return errStackUnchanged
+		}
 
-	// Initialize if nil
-	if config.Relationships == nil {
-		config.Relationships = make(map[string]string)
+		config.ForkPoints[childBranch] = parentSHA
+		return nil
+	})
+}
+
+// RecordRebasedOnto: Record parentBranch as a branch's parent after rebasing it onto base,
+// the remote copy of the parent, so its fork point is the commit it now builds on
+func (g *GitExecutor) RecordRebasedOnto(childBranch, parentBranch, base string) error {
+	if _, err := g.GetBranchSHA(parentBranch); err != nil {
+		return err
+	}
+	baseSHA, err := g.resolveCommit(base)
+	if err != nil {
+		return err
 	}
 
-	return &config, nil
+	return g.UpdateStackConfig(func(config *StackConfig) error {
+		config.Relationships[childBranch] = parentBranch
+		config.ForkPoints[childBranch] = baseSHA
+		return nil
+	})
 }
 
-// RecordBranchRelationship: Record a branch relationship in the stack config
-func (g *GitExecutor) RecordBranchRelationship(childBranch, parentBranch string) error {
+// ForkPoint: Return the commit a branch was last based on, so that only its own commits are restacked.
+// Falls back to the merge base with parentBranch when nothing usable was recorded.
+func (g *GitExecutor) ForkPoint(branch, parentBranch string) (string, error) {
 	config, err := g.LoadStackConfig()
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// Add or update relationship
-	config.Relationships[childBranch] = parentBranch
+	// A recorded fork point is only usable while it is still in the branch's history
+	if recorded := config.ForkPoints[branch]; recorded != "" {
+		if g.IsAncestor(recorded, branch) {
+			return recorded, nil
+		}
+	}
 
-	return g.SaveStackConfig(config)
+	if output, err := g.Execute("merge-base", "--fork-point", parentBranch, branch); err == nil {
+		return strings.TrimSpace(output), nil
+	}
+
+	output, err := g.Execute("merge-base", parentBranch, branch)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// resolveCommit: Return the commit SHA a revision points at
+func (g *GitExecutor) resolveCommit(rev string) (string, error) {
+	output, err := g.Execute("rev-parse", "--verify", rev+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
 }
 
 // getBranchesWithCommits: Return all local branches with their HEAD commit SHAs
@@ -168,79 +249,6 @@ func (g *GitExecutor) getBranchesWithCommits() (map[string]string, error) {
 	return branches, nil
 }
 
-// findParentCommits: gets the parents commit for each branch's HEAD
-func (g *GitExecutor) findParentCommits(branches map[string]string) (map[string]*BranchInfo, error) {
-	result := make(map[string]*BranchInfo)
-
-	for branchName, commitSHA := range branches {
-		// Get the parent commit SHA
-		output, err := g.Execute("rev-list", "--parents", "-n", "1", commitSHA)
-		if err != nil {
-			continue // Skip if error
-		}
-
-		// Parse the output: <commit-sha> <parent1-sha> [<parent2-sha> ...]
-		parts := strings.Fields(strings.TrimSpace(output))
-		if len(parts) >= 2 { // At least commit and one parent
-			parentSHA := parts[1] // Use first parent if multiple (merge commit)
-
-			result[branchName] = &BranchInfo{
-				Name:      branchName,
-				CommitSHA: commitSHA,
-				ParentSHA: parentSHA,
-			}
-		}
-	}
-
-	return result, nil
-}
-
-// FindMostLikelyParent: finds the most likely parent branch for a branch
-func (g *GitExecutor) FindMostLikelyParent(branch string, branchInfos map[string]*BranchInfo) string {
-	// Can't find parent if branch info is missing
-	branchInfo, exists := branchInfos[branch]
-	if !exists {
-		return ""
-	}
-
-	// Check direct commit parent relationship
-	for otherName, otherInfo := range branchInfos {
-		if otherName == branch {
-			continue // Skip self
-		}
-
-		if otherInfo.CommitSHA == branchInfo.ParentSHA {
-			return otherName // Found direct parent
-		}
-	}
-
-	// If no direct parent, find branch containing this branch's parent commit
-	for otherName, _ := range branchInfos {
-		if otherName == branch {
-			continue // Skip self
-		}
-
-		// Check if parent commit is contained in the other branch
-		output, err := g.Execute("branch", "--contains", branchInfo.ParentSHA)
-		if err != nil {
-			continue
-		}
-
-		// Parse branches containing the commit
-		containingBranches := strings.Split(strings.TrimSpace(output), "\n")
-		for _, line := range containingBranches {
-			name := strings.TrimSpace(strings.TrimPrefix(line, "*"))
-			name = strings.TrimSpace(name)
-
-			if name == otherName {
-				return otherName
-			}
-		}
-	}
-
-	return "" // No parent found
-}
-
 // BuildBranchStack: Analyze git history and build the branch stack
 func (g *GitExecutor) BuildBranchStack() (*BranchStack, error) {
 	// get all local branches
@@ -249,12 +257,6 @@ func (g *GitExecutor) BuildBranchStack() (*BranchStack, error) {
 		return nil, err
 	}
 
-	// find parent commit SHA for each branch's HEAD commit
-	branchesWithParents, err := g.findParentCommits(branchesWithCommits)
-	if err != nil {
-		return nil, err
-	}
-
 	// load saved stack config
 	config, err := g.LoadStackConfig()
 	if err != nil {
@@ -262,24 +264,48 @@ func (g *GitExecutor) BuildBranchStack() (*BranchStack, error) {
 	}
 
 	// build the tree structure
-	stack, err := g.buildBranchTree(branchesWithCommits, branchesWithParents, config)
+	loaded := config.clone()
+	stack, err := g.buildBranchTree(branchesWithCommits, config)
 	if err != nil {
 		return nil, err
 	}
 
-	// save updated relationships to config
-	if err = g.SaveStackConfig(config); err != nil {
-		// Non-fatal error, just continue for now
-		fmt.Fprintf(os.Stderr, "Warning: Failed to save stack config: %v\n", err)
+	// save what self healing changed, on top of anything written since we loaded
+	if !reflect.DeepEqual(loaded, config) {
+		err = g.UpdateStackConfig(func(latest *StackConfig) error {
+			applyChanges(latest.Relationships, loaded.Relationships, config.Relationships)
+			applyChanges(latest.ForkPoints, loaded.ForkPoints, config.ForkPoints)
+			if config.Metadata.MainBranch != loaded.Metadata.MainBranch {
+				latest.Metadata.MainBranch = config.Metadata.MainBranch
+			}
+			return nil
+		})
+		if err != nil {
+			// Non-fatal error, just continue for now
+			fmt.Fprintf(os.Stderr, "Warning: Failed to save stack config: %v\n", err)
+		}
 	}
 
 	return stack, nil
 }
 
+// applyChanges: Apply the entries that differ between before and after to latest
+func applyChanges(latest, before, after map[string]string) {
+	for key, value := range after {
+		if previous, existed := before[key]; !existed || previous != value {
+			latest[key] = value
+		}
+	}
+	for key := range before {
+		if _, exists := after[key]; !exists {
+			delete(latest, key)
+		}
+	}
+}
+
 // buildBranchTree: Construct the branch hierarchy with self healing
 func (g *GitExecutor) buildBranchTree(
 	branches map[string]string,
-	branchInfos map[string]*BranchInfo,
 	config *StackConfig) (*BranchStack, error) {
 
 	// Create nodes for each branch
@@ -309,12 +335,22 @@ func (g *GitExecutor) buildBranchTree(
 		}
 	}
 
-	// Clean up deleted branches from config
+	// Clean up deleted branches from config, keeping branches that only exist on origin
+	// so that relationships fetched from teammates survive until they are checked out
+	remoteBranches, err := g.remoteBranchSHAs()
+	if err != nil {
+		remoteBranches = make(map[string]string)
+	}
 	for child := range config.Relationships {
-		if nodes[child] == nil {
+		if nodes[child] == nil && remoteBranches["origin/"+child] == "" {
 			delete(config.Relationships, child)
 		}
 	}
+	for child := range config.ForkPoints {
+		if nodes[child] == nil {
+			delete(config.ForkPoints, child)
+		}
+	}
 
 	// Clear duplicate entries in relationships
 	// Find branches with multiple parents
@@ -347,25 +383,99 @@ func (g *GitExecutor) buildBranchTree(
 		// Only add to children if not already processed
 		if !processedBranches[child] {
 			nodes[parent].Children = append(nodes[parent].Children, nodes[child])
+			nodes[child].Parent = nodes[parent]
 			processedBranches[child] = true
 		}
 	}
 
-	// Process branches without explicit relationships
-	for name, node := range nodes {
+	// Branches with a recorded place in the stack, taken before any guess is recorded
+	// so that guesses don't depend on the order branches are visited in
+	tracked := make(map[string]bool)
+	for child, parent := range config.Relationships {
+		tracked[child] = true
+		tracked[parent] = true
+	}
+
+	// Branches without explicit relationships
+	var untracked []string
+	for name := range nodes {
 		if processedBranches[name] || name == mainBranch || branchHasParent[name] {
 			continue // Skip already processed or main branch
 		}
+		if config.Relationships[name] != "" {
+			continue // Recorded parent isn't checked out here, don't replace it with a guess
+		}
+		untracked = append(untracked, name)
+	}
+	sort.Strings(untracked)
+
+	// Anything computed before for the same commits comes from the cache
+	cache := g.loadCache()
+	defer g.saveCache(cache)
+
+	// Every count that isn't cached comes from one counter, started the first time it's needed:
+	// guesses count from each untracked branch, health from each parent and trunk
+	var counter commitCounter
+	getCounter := func() (commitCounter, error) {
+		if counter != nil {
+			return counter, nil
+		}
+		bases := append([]string{mainBranch}, untracked...)
+		seenBase := make(map[string]bool)
+		for _, parent := range config.Relationships {
+			if nodes[parent] != nil && !seenBase[parent] && parent != mainBranch {
+				seenBase[parent] = true
+				bases = append(bases, parent)
+			}
+		}
 
-		// Try to find most likely parent
-		parentName := g.FindMostLikelyParent(name, branchInfos)
-		if parentName != "" && nodes[parentName] != nil {
-			// Found parent, update relationships
-			nodes[parentName].Children = append(nodes[parentName].Children, node)
-			config.Relationships[name] = parentName
-			processedBranches[name] = true
-			branchHasParent[name] = true
+		var err error
+		counter, err = g.newCommitCounter(branches, bases)
+		return counter, err
+	}
+
+	snapshot := guessSnapshot(branches, mainBranch, tracked, untracked)
+	guesses, cached := cache.guesses(snapshot)
+	if !cached {
+		guesses = make(map[string]*ParentGuess)
+		if len(untracked) > 0 {
+			counter, err := getCounter()
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range untracked {
+				guess, err := inferParent(name, branches, mainBranch, tracked, counter)
+				if err != nil {
+					return nil, err
+				}
+				if guess != nil {
+					guesses[name] = guess
+				}
+			}
 		}
+		cache.setGuesses(snapshot, guesses)
+	}
+
+	// Process branches without explicit relationships
+	var suggested []*ParentGuess
+	for _, name := range untracked {
+		node := nodes[name]
+		guess := guesses[name]
+		if guess == nil || nodes[guess.Parent] == nil {
+			continue
+		}
+		if guess.Confidence == ConfidenceLow {
+			suggested = append(suggested, guess)
+			continue
+		}
+
+		// Confident enough, update relationships
+		parentName := guess.Parent
+		nodes[parentName].Children = append(nodes[parentName].Children, node)
+		node.Parent = nodes[parentName]
+		config.Relationships[name] = parentName
+		processedBranches[name] = true
+		branchHasParent[name] = true
 	}
 
 	// Collect root nodes and orphans
@@ -408,31 +518,248 @@ func (g *GitExecutor) buildBranchTree(
 		nodes[currentBranch].IsHead = true
 	}
 
-	// Add health information (ahead/behind counts)
+	// Note branches that are checked out elsewhere and can't be checked out here
+	if worktreeBranches, err := g.OtherWorktreeBranches(); err == nil {
+		for branch, path := range worktreeBranches {
+			if nodes[branch] != nil {
+				nodes[branch].Worktree = path
+			}
+		}
+	}
+
+	// Add health information (ahead/behind counts), a branch is merged once its parent has all its commits
+	var trunkChildren []string
 	for childName, parentName := range config.Relationships {
 		// Skip if either branch is missing
 		if nodes[childName] == nil || nodes[parentName] == nil {
 			continue
 		}
 
-		// Calculate ahead/behind
-		ahead, behind, err := g.GetAheadBehind(childName, parentName)
-		if err == nil {
-			nodes[childName].Ahead = ahead
-			nodes[childName].Behind = behind
+		count, cached := cache.count(branches[childName], branches[parentName])
+		if !cached {
+			counter, err := getCounter()
+			if err != nil {
+				return nil, err
+			}
+			counts, err := counter.counts(parentName)
+			if err != nil {
+				return nil, err
+			}
+			if count, cached = counts[childName]; cached {
+				cache.setCount(branches[childName], branches[parentName], count)
+			}
 		}
-
-		// Check if merged
-		merged, err := g.IsBranchMerged(childName, parentName)
-		if err == nil {
-			nodes[childName].IsMerged = merged
+		if cached {
+			nodes[childName].Ahead = count.Ahead
+			nodes[childName].Behind = count.Behind
+			nodes[childName].IsMerged = count.Ahead == 0
+		}
+		if parentName == mainBranch {
+			trunkChildren = append(trunkChildren, childName)
 		}
 	}
 
+	// Detect branches that landed in trunk through a squash or rebase merge
+	g.markMergedIntoTrunk(nodes, trunkChildren, mainBranch, config.ForkPoints, cache)
+
+	named := make(map[string]*NamedStack)
+	for _, stack := range config.NamedStacks() {
+		named[stack.Root] = stack
+	}
+
 	return &BranchStack{
 		Roots:      rootNodes,
 		AllNodes:   nodes,
 		MainBranch: mainBranch,
 		Orphans:    orphanNodes,
+		Named:      named,
+		Suggested:  suggested,
 	}, nil
 }
+
+// markMergedIntoTrunk: Check which children of trunk have landed in it, a few at a time
+func (g *GitExecutor) markMergedIntoTrunk(nodes map[string]*BranchNode, children []string, trunk string, forkPoints map[string]string, cache *stackCache) {
+	if len(children) == 0 {
+		return
+	}
+	trunkSHA := nodes[trunk].CommitSHA
+
+	var unknown []string
+	for _, child := range children {
+		node := nodes[child]
+		if landed, cached := cache.landed(node.CommitSHA, trunkSHA, forkPoints[child]); cached {
+			node.MergedIntoTrunk = landed
+		} else {
+			unknown = append(unknown, child)
+		}
+	}
+	if len(unknown) == 0 {
+		return
+	}
+	children = unknown
+
+	// Trees for tree equivalence are read through one cat-file process
+	resolveTree := g.resolveTree
+	if objects, err := g.startCatFile(); err == nil {
+		defer objects.Close()
+		resolveTree = objects.tree
+	}
+
+	merged := make([]bool, len(children))
+	failed := make([]bool, len(children))
+	runBounded(len(children), maxGitJobs, func(i int) {
+		node := nodes[children[i]]
+		landed, err := g.landedInTrunk(node.CommitSHA, trunk, forkPoints[node.Name], node.IsMerged, resolveTree)
+		merged[i], failed[i] = landed, err != nil
+	})
+
+	for i, child := range children {
+		node := nodes[child]
+		node.MergedIntoTrunk = merged[i] && !failed[i]
+		if !failed[i] {
+			cache.setLanded(node.CommitSHA, trunkSHA, forkPoints[child], merged[i])
+		}
+	}
+}
+
+// DescendantSteps: Return the restack steps for every descendant of a branch,
+// ordered so that each parent is rebased before its children
+func (s *BranchStack) DescendantSteps(branch string) ([]RestackStep, error) {
+	node := s.AllNodes[branch]
+	if node == nil {
+		return nil, &BranchNotFoundError{BranchName: branch}
+	}
+
+	var steps []RestackStep
+	visited := make(map[string]bool)
+
+	var walk func(parent *BranchNode)
+	walk = func(parent *BranchNode) {
+		visited[parent.Name] = true
+
+		// Sort children so that forked siblings are always restacked in the same order
+		children := make([]*BranchNode, len(parent.Children))
+		copy(children, parent.Children)
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].Name < children[j].Name
+		})
+
+		for _, child := range children {
+			if visited[child.Name] {
+				continue // Guard against malformed relationships
+			}
+			steps = append(steps, RestackStep{Branch: child.Name, Parent: parent.Name})
+			walk(child)
+		}
+	}
+	walk(node)
+
+	return steps, nil
+}
+
+// MergedIntoTrunk: Return the branches whose changes have already landed in trunk
+func (s *BranchStack) MergedIntoTrunk() []*BranchNode {
+	var merged []*BranchNode
+	for _, node := range s.AllNodes {
+		if node.MergedIntoTrunk {
+			merged = append(merged, node)
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Name < merged[j].Name
+	})
+	return merged
+}
+
+// ReparentChildren: Move every child of a branch onto a new parent, keeping their fork points
+// so that a later restack only moves each child's own commits
+func (g *GitExecutor) ReparentChildren(branch, newParent string) ([]string, error) {
+	branchSHA, err := g.resolveCommit(branch)
+	if err != nil {
+		return nil, err
+	}
+
+	var children []string
+	err = g.UpdateStackConfig(func(config *StackConfig) error {
+		children = nil
+		for child, parent := range config.Relationships {
+			if parent != branch {
+				continue
+			}
+
+			config.Relationships[child] = newParent
+			if config.ForkPoints[child] == "" {
+				config.ForkPoints[child] = branchSHA
+			}
+			children = append(children, child)
+		}
+		sort.Strings(children)
+
+		// A named stack whose root is going away continues from its first child
+		for _, stack := range config.Stacks {
+			if stack.Root == branch && len(children) > 0 {
+				stack.Root = children[0]
+			}
+		}
+		return nil
+	})
+
+	return children, err
+}
+
+// InsertBranch: Create newBranch on top of after and move the given children of after onto it,
+// keeping their fork points so that a later restack only moves each child's own commits
+func (g *GitExecutor) InsertBranch(newBranch, after string, children []string) error {
+	afterSHA, err := g.resolveCommit(after)
+	if err != nil {
+		return err
+	}
+
+	if _, err := g.Execute("checkout", "-b", newBranch, after); err != nil {
+		return err
+	}
+
+	return g.UpdateStackConfig(func(config *StackConfig) error {
+		for _, child := range children {
+			config.Relationships[child] = newBranch
+			if config.ForkPoints[child] == "" {
+				config.ForkPoints[child] = afterSHA
+			}
+		}
+
+		config.Relationships[newBranch] = after
+		config.ForkPoints[newBranch] = afterSHA
+		return nil
+	})
+}
+
+// MoveBranch: Record newParent as a branch's parent, keeping where it forked from oldParent
+// so that a later restack only moves the branch's own commits. oldParent is the parent in the
+// built stack, which may have been inferred rather than recorded.
+func (g *GitExecutor) MoveBranch(branch, oldParent, newParent string) error {
+	var forkPoint string
+	if oldParent != "" {
+		var err error
+		if forkPoint, err = g.ForkPoint(branch, oldParent); err != nil {
+			return err
+		}
+	}
+
+	return g.UpdateStackConfig(func(config *StackConfig) error {
+		config.Relationships[branch] = newParent
+		if forkPoint != "" {
+			config.ForkPoints[branch] = forkPoint
+		}
+		return nil
+	})
+}
+
+// ForgetBranch: Remove a branch from the recorded relationships
+func (g *GitExecutor) ForgetBranch(branch string) error {
+	return g.UpdateStackConfig(func(config *StackConfig) error {
+		delete(config.Relationships, branch)
+		delete(config.ForkPoints, branch)
+		return nil
+	})
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDescendantSteps(t *testing.T) {
+	stack := stackFrom(map[string]string{
+		"main": "",
+		"a":    "main",
+		"b":    "a",
+		"c":    "a",
+		"d":    "b",
+		"x":    "y",
+		"y":    "x",
+	})
+
+	tests := []struct {
+		name    string
+		branch  string
+		want    []RestackStep
+		wantErr bool
+	}{
+		{
+			name:   "siblings in name order, parents first",
+			branch: "a",
+			want: []RestackStep{
+				{Branch: "b", Parent: "a"},
+				{Branch: "d", Parent: "b"},
+				{Branch: "c", Parent: "a"},
+			},
+		},
+		{
+			name:   "no descendants",
+			branch: "c",
+		},
+		{
+			name:   "cycle is walked once",
+			branch: "x",
+			want:   []RestackStep{{Branch: "y", Parent: "x"}},
+		},
+		{
+			name:    "unknown branch",
+			branch:  "missing",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stack.DescendantSteps(tt.branch)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NamedStack gives a name and context to the stack rooted at a branch just above trunk.
+// Every descendant of the root belongs to the stack.
+type NamedStack struct {
+	Name        string    `yaml:"-"` // Key in StackConfig.Stacks
+	Root        string    `yaml:"root"`
+	Description string    `yaml:"description,omitempty"`
+	Owner       string    `yaml:"owner,omitempty"`
+	Issue       string    `yaml:"issue,omitempty"` // Linked ticket, e.g. PROJ-123 or a URL
+	CreatedAt   time.Time `yaml:"created_at"`
+}
+
+// NamedStacks: Return the named stacks in the config, sorted by name
+func (c *StackConfig) NamedStacks() []*NamedStack {
+	var stacks []*NamedStack
+	for name, stack := range c.Stacks {
+		stack.Name = name
+		stacks = append(stacks, stack)
+	}
+
+	sort.Slice(stacks, func(i, j int) bool {
+		return stacks[i].Name < stacks[j].Name
+	})
+	return stacks
+}
+
+// LoadNamedStacks: Return every named stack, sorted by name
+func (g *GitExecutor) LoadNamedStacks() ([]*NamedStack, error) {
+	config, err := g.LoadStackConfig()
+	if err != nil {
+		return nil, err
+	}
+	return config.NamedStacks(), nil
+}
+
+// SaveNamedStack: Create or update a named stack. A branch can only be the root of one named stack.
+func (g *GitExecutor) SaveNamedStack(stack *NamedStack) error {
+	if stack.CreatedAt.IsZero() {
+		stack.CreatedAt = time.Now()
+	}
+
+	return g.UpdateStackConfig(func(config *StackConfig) error {
+		for _, other := range config.NamedStacks() {
+			if other.Name != stack.Name && other.Root == stack.Root {
+				return fmt.Errorf("%s is already the root of stack %s", stack.Root, other.Name)
+			}
+		}
+
+		config.Stacks[stack.Name] = stack
+		return nil
+	})
+}
+
+// CurrentUser: Return the email git commits as, used as the default stack owner
+func (g *GitExecutor) CurrentUser() string {
+	output, err := g.Execute("config", "--get", "user.email")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// Members: Return the branches of a named stack, root first, parents before children
+func (s *BranchStack) Members(stack *NamedStack) []string {
+	if s.AllNodes[stack.Root] == nil {
+		return nil
+	}
+
+	steps, err := s.DescendantSteps(stack.Root)
+	if err != nil {
+		return nil
+	}
+
+	branches := []string{stack.Root}
+	for _, step := range steps {
+		branches = append(branches, step.Branch)
+	}
+	return branches
+}
@@ -0,0 +1,485 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the stack store backends, as set with `git config stacksmith.store <name>`
+const (
+	StoreFile      = "file"       // .git/stacksmith/stack.yml, the default
+	StoreGitConfig = "git-config" // branch.<name>.stacksmith-parent keys in git config
+	StoreMemory    = "memory"     // Kept in memory only, for tests and scripting
+)
+
+// StackStore persists the stack config between runs
+type StackStore interface {
+	Load() (*StackConfig, error)
+	Save(config *StackConfig) error
+
+	// Update applies update to the latest config and saves it, without letting other
+	// writers in between. Returning errStackUnchanged from update skips the write.
+	Update(update func(config *StackConfig) error) error
+}
+
+// errStackUnchanged tells StackStore.Update that there is nothing to write
+var errStackUnchanged = errors.New("stack config unchanged")
+
+// NewStackStore returns the store backend with the given name, defaulting to the YAML file
+func NewStackStore(name string, g *GitExecutor) (StackStore, error) {
+	switch name {
+	case "", StoreFile:
+		return &FileStore{git: g}, nil
+	case StoreGitConfig:
+		return &GitConfigStore{git: g}, nil
+	case StoreMemory:
+		return NewMemoryStore(), nil
+	}
+	return nil, fmt.Errorf("unknown stack store %q, expected %s, %s or %s", name, StoreFile, StoreGitConfig, StoreMemory)
+}
+
+// newStackConfig returns an empty config with its maps initialised
+func newStackConfig() *StackConfig {
+	return &StackConfig{
+		Relationships: make(map[string]string),
+		ForkPoints:    make(map[string]string),
+		Stacks:        make(map[string]*NamedStack),
+	}
+}
+
+// Bounds on how long to wait for another stacksmith process to finish writing stack.yml
+const (
+	lockRetries    = 40
+	lockRetryDelay = 50 * time.Millisecond
+)
+
+// FileStore keeps the stack config in .git/stacksmith/stack.yml. Writes follow git's
+// lock file convention: the new content is written to stack.yml.lock, which is created
+// exclusively, then renamed over stack.yml, so readers never see a partial file and
+// concurrent writers wait for each other.
+type FileStore struct {
+	git *GitExecutor
+}
+
+// path returns the location of stack.yml
+func (s *FileStore) path() (string, error) {
+	stacksmithDir, err := s.git.stacksmithDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stacksmithDir, "stack.yml"), nil
+}
+
+// Load reads stack.yml, returning an empty config if it doesn't exist yet.
+// Files from older versions of stacksmith are migrated to the current schema.
+func (s *FileStore) Load() (*StackConfig, error) {
+	filePath, err := s.path()
+	if err != nil {
+		return nil, err
+	}
+
+	config, migrated, err := s.read(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the upgrade back straight away, so the file is only migrated once
+	if migrated && !s.git.DryRun {
+		if err := s.Update(func(*StackConfig) error { return nil }); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// read parses stack.yml, migrating it in memory. Returns whether the file is from an older schema.
+func (s *FileStore) read(filePath string) (*StackConfig, bool, error) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return newStackConfig(), false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	migrated, version, err := migrateStackConfig(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var config StackConfig
+	if err := yaml.Unmarshal(migrated, &config); err != nil {
+		return nil, false, err
+	}
+
+	return &config, version < StackSchemaVersion, nil
+}
+
+// backupOlderSchema keeps a copy of stack.yml next to it if it is from an older schema,
+// just before it is replaced with the current one
+func backupOlderSchema(filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, version, err := migrateStackConfig(data)
+	if err != nil || version >= StackSchemaVersion {
+		return err
+	}
+	return os.WriteFile(fmt.Sprintf("%s.v%d.bak", filePath, version), data, 0644)
+}
+
+// Save writes stack.yml, creating .git/stacksmith if needed
+func (s *FileStore) Save(config *StackConfig) error {
+	filePath, err := s.path()
+	if err != nil {
+		return err
+	}
+
+	lock, err := s.lock(filePath)
+	if err != nil {
+		return err
+	}
+	return s.commit(lock, filePath, config)
+}
+
+// Update reads stack.yml, applies update and writes the result, holding the lock
+// throughout so that no other process can write in between
+func (s *FileStore) Update(update func(config *StackConfig) error) error {
+	filePath, err := s.path()
+	if err != nil {
+		return err
+	}
+
+	lock, err := s.lock(filePath)
+	if err != nil {
+		return err
+	}
+
+	config, _, err := s.read(filePath)
+	if err == nil {
+		err = update(config)
+	}
+	if err != nil {
+		rollback(lock)
+		if errors.Is(err, errStackUnchanged) {
+			return nil
+		}
+		return err
+	}
+
+	return s.commit(lock, filePath, config)
+}
+
+// lock creates stack.yml.lock, waiting for a while if another process holds it
+func (s *FileStore) lock(filePath string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, err
+	}
+
+	lockPath := filePath + ".lock"
+	for attempt := 0; ; attempt++ {
+		lock, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return lock, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if attempt == lockRetries {
+			return nil, &StackLockedError{LockPath: lockPath}
+		}
+		time.Sleep(lockRetryDelay)
+	}
+}
+
+// commit writes config into the lock file and renames it over stack.yml, releasing the lock
+func (s *FileStore) commit(lock *os.File, filePath string, config *StackConfig) error {
+	yamlData, err := yaml.Marshal(config)
+	if err != nil {
+		rollback(lock)
+		return err
+	}
+
+	// Add header comment
+	header := "# Stacksmith branch relationships\n" +
+		fmt.Sprintf("# Last updated: %s\n\n", config.Metadata.LastUpdated.Format("2006-01-02 15:04:05"))
+
+	if err := backupOlderSchema(filePath); err != nil {
+		rollback(lock)
+		return err
+	}
+
+	if _, err := lock.WriteString(header + string(yamlData)); err != nil {
+		rollback(lock)
+		return err
+	}
+	if err := lock.Sync(); err != nil {
+		rollback(lock)
+		return err
+	}
+	if err := lock.Close(); err != nil {
+		os.Remove(lock.Name())
+		return err
+	}
+
+	if err := os.Rename(lock.Name(), filePath); err != nil {
+		os.Remove(lock.Name())
+		return err
+	}
+	return nil
+}
+
+// rollback releases a lock without touching stack.yml
+func rollback(lock *os.File) {
+	lock.Close()
+	os.Remove(lock.Name())
+}
+
+// Keys used by GitConfigStore
+const (
+	gitConfigParentKey    = "stacksmith-parent"
+	gitConfigForkPointKey = "stacksmith-forkpoint"
+	gitConfigMainBranch   = "stacksmith.mainbranch"
+	gitConfigStackSection = "stacksmith-stack"
+)
+
+// GitConfigStore keeps relationships next to each branch's other settings in git config,
+// as branch.<name>.stacksmith-parent and branch.<name>.stacksmith-forkpoint, so other
+// tools that read git config can see them. Named stacks live in stacksmith-stack.<name>.*.
+type GitConfigStore struct {
+	git *GitExecutor
+}
+
+// Load reads every stacksmith key from git config
+func (s *GitConfigStore) Load() (*StackConfig, error) {
+	config := newStackConfig()
+
+	entries, err := s.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range entries {
+		if key == gitConfigMainBranch {
+			config.Metadata.MainBranch = value
+			continue
+		}
+
+		// Names may contain dots, so the subsection runs from the first to the last one
+		first, last := strings.Index(key, "."), strings.LastIndex(key, ".")
+		if first == last {
+			continue
+		}
+		section, name, variable := key[:first], key[first+1:last], key[last+1:]
+
+		switch {
+		case section == "branch" && variable == gitConfigParentKey:
+			config.Relationships[name] = value
+		case section == "branch" && variable == gitConfigForkPointKey:
+			config.ForkPoints[name] = value
+		case section == gitConfigStackSection:
+			stack := config.Stacks[name]
+			if stack == nil {
+				stack = &NamedStack{}
+				config.Stacks[name] = stack
+			}
+			switch variable {
+			case "root":
+				stack.Root = value
+			case "description":
+				stack.Description = value
+			case "owner":
+				stack.Owner = value
+			case "issue":
+				stack.Issue = value
+			case "created-at":
+				stack.CreatedAt, _ = time.Parse(time.RFC3339, value)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// entries returns the stacksmith keys currently in git config
+func (s *GitConfigStore) entries() (map[string]string, error) {
+	entries := make(map[string]string)
+
+	// -z keeps multi-line values such as descriptions intact
+	output, err := s.git.Execute("config", "-z", "--get-regexp", `^branch\..*\.stacksmith-|^stacksmith\.mainbranch$|^stacksmith-stack\.`)
+	if err != nil {
+		// git config exits with 1 when nothing matches, anything else is a real failure
+		var gitErr *GitError
+		if errors.As(err, &gitErr) && gitErr.ExitCode() == 1 {
+			return entries, nil
+		}
+		return nil, err
+	}
+
+	for _, entry := range strings.Split(output, "\x00") {
+		parts := strings.SplitN(entry, "\n", 2)
+		if len(parts) == 2 {
+			entries[parts[0]] = parts[1]
+		}
+	}
+	return entries, nil
+}
+
+// Save writes the keys that changed and removes the ones that are gone
+func (s *GitConfigStore) Save(config *StackConfig) error {
+	current, err := s.entries()
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]string)
+	if config.Metadata.MainBranch != "" {
+		wanted[gitConfigMainBranch] = config.Metadata.MainBranch
+	}
+	for branch, parent := range config.Relationships {
+		wanted["branch."+branch+"."+gitConfigParentKey] = parent
+	}
+	for branch, sha := range config.ForkPoints {
+		wanted["branch."+branch+"."+gitConfigForkPointKey] = sha
+	}
+	for name, stack := range config.Stacks {
+		prefix := gitConfigStackSection + "." + name + "."
+		fields := map[string]string{
+			"root":        stack.Root,
+			"description": stack.Description,
+			"owner":       stack.Owner,
+			"issue":       stack.Issue,
+			"created-at":  stack.CreatedAt.Format(time.RFC3339),
+		}
+		for variable, value := range fields {
+			if value != "" {
+				wanted[prefix+variable] = value
+			}
+		}
+	}
+
+	// Sort so the dry run plan is stable
+	var keys []string
+	for key := range current {
+		keys = append(keys, key)
+	}
+	for key := range wanted {
+		if _, exists := current[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, keep := wanted[key]
+
+		var err error
+		switch {
+		case !keep:
+			_, err = s.git.Execute("config", "--unset", key)
+		case value != current[key]:
+			_, err = s.git.Execute("config", key, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update loads the config, applies update and saves the keys that changed.
+// git config locks the file for each individual write.
+func (s *GitConfigStore) Update(update func(config *StackConfig) error) error {
+	config, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if err := update(config); err != nil {
+		if errors.Is(err, errStackUnchanged) {
+			return nil
+		}
+		return err
+	}
+	return s.Save(config)
+}
+
+// MemoryStore keeps the stack config in memory, so tests can run without touching disk
+type MemoryStore struct {
+	mu     sync.Mutex
+	config *StackConfig
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{config: newStackConfig()}
+}
+
+// Load returns a copy of the stored config
+func (s *MemoryStore) Load() (*StackConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config.clone(), nil
+}
+
+// Save replaces the stored config with a copy of config
+func (s *MemoryStore) Save(config *StackConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config.clone()
+	return nil
+}
+
+// Update applies update to a copy of the stored config and keeps the result
+func (s *MemoryStore) Update(update func(config *StackConfig) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config := s.config.clone()
+	if err := update(config); err != nil {
+		if errors.Is(err, errStackUnchanged) {
+			return nil
+		}
+		return err
+	}
+	s.config = config
+	return nil
+}
+
+// clone returns a deep copy of the config
+func (c *StackConfig) clone() *StackConfig {
+	copied := newStackConfig()
+	for child, parent := range c.Relationships {
+		copied.Relationships[child] = parent
+	}
+	for child, sha := range c.ForkPoints {
+		copied.ForkPoints[child] = sha
+	}
+	for name, stack := range c.Stacks {
+		stackCopy := *stack
+		copied.Stacks[name] = &stackCopy
+	}
+	copied.Version = c.Version
+	copied.Metadata = c.Metadata
+	return copied
+}
+
+// stackStore returns the configured store, falling back to stack.yml
+func (g *GitExecutor) stackStore() StackStore {
+	if g.Store != nil {
+		return g.Store
+	}
+	return &FileStore{git: g}
+}
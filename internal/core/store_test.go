@@ -0,0 +1,96 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStackStoresRoundTrip(t *testing.T) {
+	for _, name := range []string{StoreFile, StoreGitConfig, StoreMemory} {
+		t.Run(name, func(t *testing.T) {
+			g := fixtureRepo(t, [][]string{commit("m1")})
+			store, err := NewStackStore(name, g)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			empty, err := store.Load()
+			if err != nil {
+				t.Fatalf("loading an empty store: %v", err)
+			}
+			if len(empty.Relationships) != 0 || len(empty.ForkPoints) != 0 || len(empty.Stacks) != 0 {
+				t.Fatalf("empty store loaded %+v", empty)
+			}
+
+			config := newStackConfig()
+			config.Version = StackSchemaVersion
+			config.Metadata.MainBranch = "main"
+			config.Relationships["a"] = "main"
+			config.Relationships["feature/b.v2"] = "a"
+			config.ForkPoints["a"] = "0123456789abcdef0123456789abcdef01234567"
+			config.Stacks["payments"] = &NamedStack{
+				Name:        "payments",
+				Root:        "a",
+				Description: "Split the payment flow\nacross two PRs",
+				Owner:       "sam@example.com",
+				CreatedAt:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			}
+			if err := store.Save(config); err != nil {
+				t.Fatal(err)
+			}
+
+			// Removing entries has to reach the store as well
+			err = store.Update(func(config *StackConfig) error {
+				delete(config.Relationships, "feature/b.v2")
+				config.Relationships["c"] = "a"
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := store.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantParents := map[string]string{"a": "main", "c": "a"}
+			if !reflect.DeepEqual(loaded.Relationships, wantParents) {
+				t.Errorf("relationships: got %v, want %v", loaded.Relationships, wantParents)
+			}
+			if !reflect.DeepEqual(loaded.ForkPoints, config.ForkPoints) {
+				t.Errorf("fork points: got %v, want %v", loaded.ForkPoints, config.ForkPoints)
+			}
+			if loaded.Metadata.MainBranch != "main" {
+				t.Errorf("main branch: got %q", loaded.Metadata.MainBranch)
+			}
+			stack := loaded.Stacks["payments"]
+			if stack == nil || stack.Root != "a" || stack.Description != config.Stacks["payments"].Description ||
+				stack.Owner != "sam@example.com" || !stack.CreatedAt.Equal(config.Stacks["payments"].CreatedAt) {
+				t.Errorf("named stack: got %+v", stack)
+			}
+		})
+	}
+}
+
+func TestGitConfigStoreReturnsConfigErrors(t *testing.T) {
+	g := fixtureRepo(t, [][]string{commit("m1")})
+	store := &GitConfigStore{git: g}
+
+	// A config git can't parse must not read as an empty stack
+	path := filepath.Join(g.WorkDir, ".git", "config")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("[branch \"broken\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if config, err := store.Load(); err == nil {
+		t.Fatalf("expected an error reading a corrupt config, got %+v", config)
+	}
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Worktree is a working tree attached to the repository
+type Worktree struct {
+	Path   string
+	Branch string // Empty when HEAD is detached
+}
+
+// gitDir: Return a repository directory reported by `git rev-parse` (such as --git-dir or
+// --git-common-dir) as an absolute path. Unlike <toplevel>/.git this is also right in linked
+// worktrees and submodules, where .git is a file.
+func (g *GitExecutor) gitDir(flag string) (string, error) {
+	output, err := g.Execute("rev-parse", flag)
+	if err != nil {
+		return "", err
+	}
+
+	path := strings.TrimSpace(output)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(g.WorkDir, path)
+	}
+	return filepath.Abs(path)
+}
+
+// stacksmithDir: Return the directory where stacksmith keeps its metadata, shared by every worktree
+func (g *GitExecutor) stacksmithDir() (string, error) {
+	commonDir, err := g.gitDir("--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(commonDir, "stacksmith"), nil
+}
+
+// worktreeStateDir: Return the directory for state that belongs to this worktree only,
+// such as an interrupted operation
+func (g *GitExecutor) worktreeStateDir() (string, error) {
+	gitDir, err := g.gitDir("--git-dir")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(gitDir, "stacksmith"), nil
+}
+
+// ListWorktrees returns every worktree of the repository, the main one first
+func (g *GitExecutor) ListWorktrees() ([]Worktree, error) {
+	output, err := g.Execute("worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+
+	var worktrees []Worktree
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case strings.HasPrefix(line, "worktree "):
+			worktrees = append(worktrees, Worktree{Path: strings.TrimPrefix(line, "worktree ")})
+		case strings.HasPrefix(line, "branch ") && len(worktrees) > 0:
+			worktrees[len(worktrees)-1].Branch = strings.TrimPrefix(strings.TrimPrefix(line, "branch "), "refs/heads/")
+		}
+	}
+	return worktrees, nil
+}
+
+// OtherWorktreeBranches returns the branches checked out in worktrees other than this one,
+// mapped to the path of the worktree that has them
+func (g *GitExecutor) OtherWorktreeBranches() (map[string]string, error) {
+	worktrees, err := g.ListWorktrees()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := g.Execute("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	current := samePath(strings.TrimSpace(output))
+
+	branches := make(map[string]string)
+	for _, worktree := range worktrees {
+		if worktree.Branch != "" && samePath(worktree.Path) != current {
+			branches[worktree.Branch] = worktree.Path
+		}
+	}
+	return branches, nil
+}
+
+// InWorktree returns an executor that runs in another worktree with the same settings
+func (g *GitExecutor) InWorktree(path string) *GitExecutor {
+	return &GitExecutor{
+		WorkDir: path,
+		DryRun:  g.DryRun,
+		Store:   g.Store,
+		NoCache: g.NoCache,
+		version: g.version,
+	}
+}
+
+// samePath normalises a path so that two spellings of the same directory compare equal
+func samePath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return filepath.Clean(path)
+}
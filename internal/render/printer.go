@@ -3,6 +3,7 @@ package render
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/mubbie/stacksmith/internal/core"
@@ -81,6 +82,21 @@ func (p *Printer) HandleGitError(err error) {
 			fmt.Sprintf("Merge conflict when rebasing %s onto %s", e.Branch, e.Target),
 			"Resolve the conflicts, then run 'git rebase --continue'",
 		)
+	case *core.StashConflictError:
+		p.ErrorWithSolution(
+			fmt.Sprintf("Your uncommitted changes conflict with the updated branch and were kept in %s", e.StashRef),
+			fmt.Sprintf("Resolve the conflicts in your working tree, then run 'git stash drop %s'", e.StashRef),
+		)
+	case *core.SchemaVersionError:
+		p.ErrorWithSolution(
+			fmt.Sprintf("Your stack.yml was written by a newer stacksmith (schema version %d, this one supports %d)", e.Found, e.Supported),
+			"Upgrade stacksmith to keep working with this repository",
+		)
+	case *core.StackLockedError:
+		p.ErrorWithSolution(
+			"Another stacksmith process is updating the stack metadata",
+			fmt.Sprintf("Wait for it to finish and try again. If none is running, remove %s", e.LockPath),
+		)
 	case *core.RemoteError:
 		p.ErrorWithSolution(
 			fmt.Sprintf("Error communicating with remote '%s'", e.Remote),
@@ -152,12 +168,67 @@ func (p *Printer) FixPrStart(branch, target string) {
 		Green, p.AppName, Reset, branch, target)
 }
 
+// RestackSummary prints the combined outcome of a parallel restack
+func (p *Printer) RestackSummary(restacked, failed, skipped []string) {
+	p.Divider()
+	fmt.Printf("%s%s%s 📋 Restacked %d, failed %d, skipped %d\n",
+		Green, p.AppName, Reset, len(restacked), len(failed), len(skipped))
+
+	for _, branch := range failed {
+		fmt.Printf("  %s✗ %s%s\n", Red, branch, Reset)
+	}
+	for _, branch := range skipped {
+		fmt.Printf("  %s– %s (parent failed)%s\n", Gray, branch, Reset)
+	}
+}
+
 // RetargetReminder prints reminder to retarget PR
 func (p *Printer) RetargetReminder(branch, target string) {
 	fmt.Printf("%s%s%s 📢 Don't forget to retarget the PR for %s to %s!\n",
 		Yellow, p.AppName, Reset, branch, target)
 }
 
+// OperationPaused prints how to resume or roll back an interrupted operation
+func (p *Printer) OperationPaused(command string) {
+	fmt.Printf("%s%s%s ⏸️ %s paused. Once the problem is resolved, pick up where it stopped with:\n",
+		Yellow, p.AppName, Reset, command)
+	p.CommandExample(fmt.Sprintf("stacksmith %s --continue", command))
+	fmt.Println("  or put every branch back where it started with:")
+	p.CommandExample(fmt.Sprintf("stacksmith %s --abort", command))
+}
+
+// DryRunPlan prints the operations a dry run would have performed
+func (p *Printer) DryRunPlan(plan []core.PlannedCommand) {
+	fmt.Printf("%s%s%s 📝 Dry run, nothing was changed. Planned operations:\n",
+		Cyan, p.AppName, Reset)
+
+	if len(plan) == 0 {
+		p.BulletPoint("Nothing to do")
+		return
+	}
+
+	for i, op := range plan {
+		p.Step(i+1, op.Description)
+		if command := op.String(); command != "" {
+			p.CommandExample(command)
+		}
+	}
+}
+
+// OperationLog prints the recorded operations that can be undone, newest first
+func (p *Printer) OperationLog(snapshots []*core.OperationSnapshot) {
+	fmt.Printf("%s%s%s 📜 Operations that can be undone (newest first):\n",
+		Green, p.AppName, Reset)
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snapshot := snapshots[i]
+		fmt.Printf("  %s%s%s  %s  %s\n",
+			Bold, snapshot.ID, Reset,
+			snapshot.CreatedAt.Format("2006-01-02 15:04:05"),
+			snapshot.Command)
+	}
+}
+
 // Divider prints a horizontal divider
 func (p *Printer) Divider() {
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
@@ -196,14 +267,40 @@ func (p *Printer) EndProgress(result string) {
 		Green, p.AppName, Reset, result)
 }
 
-// RenderBranchStack renders a branch stack as a text tree
+// RenderBranchStack renders a branch stack as a text tree, with each named stack under its own heading
 func (p *Printer) RenderBranchStack(stack *core.BranchStack) string {
 	var sb strings.Builder
 
+	var namedStacks []*core.NamedStack
+	for root, named := range stack.Named {
+		if stack.AllNodes[root] != nil {
+			namedStacks = append(namedStacks, named)
+		}
+	}
+	sort.Slice(namedStacks, func(i, j int) bool {
+		return namedStacks[i].Name < namedStacks[j].Name
+	})
+
+	// A named stack built on another one is drawn under its own heading only
+	for _, named := range namedStacks {
+		sb.WriteString(p.renderNamedStack(stack, named, withoutNamedStacks(stack.AllNodes[named.Root], stack.Named)))
+		sb.WriteString("\n")
+	}
+
+	// The remaining branches are drawn without the named stacks
+	roots := stack.Roots
+	if len(namedStacks) > 0 {
+		roots = nil
+		for _, root := range stack.Roots {
+			roots = append(roots, withoutNamedStacks(root, stack.Named))
+		}
+		sb.WriteString(Bold + "Other branches:" + Reset + "\n")
+	}
+
 	// Render each root node and its children
-	for i, root := range stack.Roots {
-		isLast := i == len(stack.Roots)-1 && len(stack.Orphans) == 0
-		p.renderBranchNode(&sb, root, "", isLast)
+	for i, root := range roots {
+		isLast := i == len(roots)-1 && len(stack.Orphans) == 0
+		p.renderBranchNode(&sb, root, stack.MainBranch, "", isLast)
 	}
 
 	// Render orphaned branches under special section if any
@@ -219,15 +316,63 @@ func (p *Printer) RenderBranchStack(stack *core.BranchStack) string {
 		// Render each orphan
 		for i, orphan := range stack.Orphans {
 			isLast := i == len(stack.Orphans)-1
-			p.renderBranchNode(&sb, orphan, "", isLast)
+			p.renderBranchNode(&sb, orphan, stack.MainBranch, "", isLast)
 		}
 	}
 
 	return sb.String()
 }
 
+// RenderNamedStack renders a named stack's heading followed by its branches
+func (p *Printer) RenderNamedStack(stack *core.BranchStack, named *core.NamedStack) string {
+	return p.renderNamedStack(stack, named, stack.AllNodes[named.Root])
+}
+
+// renderNamedStack renders a named stack's heading followed by the tree under root, nil if
+// the root branch is gone
+func (p *Printer) renderNamedStack(stack *core.BranchStack, named *core.NamedStack, root *core.BranchNode) string {
+	var sb strings.Builder
+
+	heading := "📚 " + Bold + named.Name + Reset
+	var details []string
+	if named.Issue != "" {
+		details = append(details, named.Issue)
+	}
+	if named.Owner != "" {
+		details = append(details, named.Owner)
+	}
+	if len(details) > 0 {
+		heading += " " + Gray + "(" + strings.Join(details, " • ") + ")" + Reset
+	}
+	sb.WriteString(heading + "\n")
+
+	if named.Description != "" {
+		sb.WriteString("   " + named.Description + "\n")
+	}
+
+	if root != nil {
+		p.renderBranchNode(&sb, root, stack.MainBranch, "", true)
+	} else {
+		sb.WriteString(Yellow + fmt.Sprintf("   ⚠️ root branch %s no longer exists", named.Root) + Reset + "\n")
+	}
+
+	return sb.String()
+}
+
+// withoutNamedStacks returns a copy of the tree under node with every named stack left out
+func withoutNamedStacks(node *core.BranchNode, named map[string]*core.NamedStack) *core.BranchNode {
+	pruned := *node
+	pruned.Children = nil
+	for _, child := range node.Children {
+		if named[child.Name] == nil {
+			pruned.Children = append(pruned.Children, withoutNamedStacks(child, named))
+		}
+	}
+	return &pruned
+}
+
 // renderBranchNode renders a single branch node and its children
-func (p *Printer) renderBranchNode(sb *strings.Builder, node *core.BranchNode, prefix string, isLast bool) {
+func (p *Printer) renderBranchNode(sb *strings.Builder, node *core.BranchNode, mainBranch, prefix string, isLast bool) {
 	// Choose the connector based on whether this is the last child
 	connector := "├── "
 	if isLast {
@@ -263,7 +408,9 @@ func (p *Printer) renderBranchNode(sb *strings.Builder, node *core.BranchNode, p
 	}
 
 	// Merged indicator
-	if node.IsMerged {
+	if node.MergedIntoTrunk {
+		statusParts = append(statusParts, "✔ landed in "+mainBranch)
+	} else if node.IsMerged {
 		statusParts = append(statusParts, "✔")
 	}
 
@@ -272,6 +419,11 @@ func (p *Printer) renderBranchNode(sb *strings.Builder, node *core.BranchNode, p
 		statusParts = append(statusParts, "⚠️ orphaned")
 	}
 
+	// Checked out in another worktree
+	if node.Worktree != "" {
+		statusParts = append(statusParts, "🌲 "+node.Worktree)
+	}
+
 	// Combine status indicators
 	statusText := ""
 	if len(statusParts) > 0 {
@@ -292,6 +444,6 @@ func (p *Printer) renderBranchNode(sb *strings.Builder, node *core.BranchNode, p
 	// Render children
 	for i, child := range node.Children {
 		isLastChild := i == len(node.Children)-1
-		p.renderBranchNode(sb, child, newPrefix, isLastChild)
+		p.renderBranchNode(sb, child, mainBranch, newPrefix, isLastChild)
 	}
 }
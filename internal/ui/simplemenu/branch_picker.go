@@ -0,0 +1,80 @@
+// ui/simplemenu/branch_picker.go
+package simplemenu
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// BranchPickerModel asks the user to pick one branch from a list
+type BranchPickerModel struct {
+	BasePrompt
+	Prompt     string
+	BranchList *SelectableList
+	Picked     string
+}
+
+// NewBranchPickerModel creates a picker for the given branches
+func NewBranchPickerModel(title, prompt string, branches []string) BranchPickerModel {
+	return BranchPickerModel{
+		BasePrompt: BasePrompt{
+			Title: title,
+		},
+		Prompt:     prompt,
+		BranchList: NewSelectableList(branches),
+	}
+}
+
+func (m BranchPickerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m BranchPickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc", "q":
+			m.Cancel()
+			return m, tea.Quit
+
+		case "up", "k":
+			m.BranchList.MoveUp()
+			return m, nil
+
+		case "down", "j":
+			m.BranchList.MoveDown()
+			return m, nil
+
+		case "enter":
+			m.Picked = m.BranchList.Items[m.BranchList.Cursor]
+			return m, tea.Quit
+		}
+	}
+
+	return m, nil
+}
+
+func (m BranchPickerModel) View() string {
+	s := m.RenderTitle()
+	s += m.Prompt + "\n\n"
+	s += m.BranchList.Render(false, false)
+	s += m.RenderHelpText("↑/↓: Navigate • Enter: Select • Esc: Cancel")
+	return s
+}
+
+// RunBranchPicker shows a list of branches and returns the one picked
+func RunBranchPicker(title, prompt string, branches []string) (string, bool) {
+	p := tea.NewProgram(NewBranchPickerModel(title, prompt, branches))
+
+	m, err := p.Run()
+	if err != nil {
+		fmt.Printf("Error running prompt: %v\n", err)
+		return "", false
+	}
+
+	if m, ok := m.(BranchPickerModel); ok && !m.IsCancelled() && m.Picked != "" {
+		return m.Picked, true
+	}
+	return "", false
+}
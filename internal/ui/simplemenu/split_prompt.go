@@ -0,0 +1,223 @@
+// ui/simplemenu/split_prompt.go
+package simplemenu
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mubbie/stacksmith/internal/ui/styles"
+)
+
+// SplitPromptModel lets the user mark where to split a branch's commits, then name each piece
+type SplitPromptModel struct {
+	BasePrompt
+	Branch     string
+	Commits    []string // Oldest first
+	Cursor     int
+	SplitAfter map[int]bool
+	Naming     bool
+	Names      []string
+	Piece      int // Piece being named
+}
+
+// NewSplitPromptModel creates a split prompt for a branch's commits, listed oldest first
+func NewSplitPromptModel(branch string, commits []string) SplitPromptModel {
+	return SplitPromptModel{
+		BasePrompt: BasePrompt{
+			Title: fmt.Sprintf("✂️ Split %s", branch),
+		},
+		Branch:     branch,
+		Commits:    commits,
+		SplitAfter: make(map[int]bool),
+	}
+}
+
+// PieceEnds returns the index of the last commit of each piece, the last one always ending at the top
+func (m SplitPromptModel) PieceEnds() []int {
+	var ends []int
+	for i := range m.Commits[:len(m.Commits)-1] {
+		if m.SplitAfter[i] {
+			ends = append(ends, i)
+		}
+	}
+	return append(ends, len(m.Commits)-1)
+}
+
+func (m SplitPromptModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m SplitPromptModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	if key.String() == "ctrl+c" {
+		m.Cancel()
+		return m, tea.Quit
+	}
+
+	if m.Naming {
+		return m.updateNaming(key)
+	}
+
+	switch key.String() {
+	case "esc", "q":
+		m.Cancel()
+		return m, tea.Quit
+
+	case "up", "k":
+		if m.Cursor > 0 {
+			m.Cursor--
+		}
+
+	case "down", "j":
+		if m.Cursor < len(m.Commits)-1 {
+			m.Cursor++
+		}
+
+	case " ":
+		if m.Cursor == len(m.Commits)-1 {
+			m.SetError("The last piece always ends at the top commit")
+			return m, nil
+		}
+		m.SplitAfter[m.Cursor] = !m.SplitAfter[m.Cursor]
+		m.ClearError()
+
+	case "enter":
+		ends := m.PieceEnds()
+		if len(ends) < 2 {
+			m.SetError("Mark at least one split point with Space")
+			return m, nil
+		}
+
+		// Suggest numbered names, with the last piece keeping the branch's own name
+		m.Names = make([]string, len(ends))
+		for i := range ends {
+			m.Names[i] = fmt.Sprintf("%s-%d", m.Branch, i+1)
+		}
+		m.Names[len(ends)-1] = m.Branch
+		m.Naming = true
+		m.Piece = 0
+		m.ClearError()
+	}
+
+	return m, nil
+}
+
+func (m SplitPromptModel) updateNaming(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.Naming = false
+		m.ClearError()
+
+	case "enter":
+		name := m.Names[m.Piece]
+		if name == "" {
+			m.SetError("Branch name cannot be empty")
+			return m, nil
+		}
+		for _, earlier := range m.Names[:m.Piece] {
+			if earlier == name {
+				m.SetError(fmt.Sprintf("%s is already used by an earlier piece", name))
+				return m, nil
+			}
+		}
+		if name == m.Branch && m.Piece != len(m.Names)-1 {
+			m.SetError(fmt.Sprintf("Only the last piece can keep the name %s", m.Branch))
+			return m, nil
+		}
+
+		m.ClearError()
+		m.Piece++
+		if m.Piece == len(m.Names) {
+			return m, tea.Quit
+		}
+
+	case "backspace":
+		if name := m.Names[m.Piece]; len(name) > 0 {
+			m.Names[m.Piece] = name[:len(name)-1]
+		}
+		m.ClearError()
+
+	default:
+		if len(msg.String()) == 1 && msg.String() != " " {
+			m.Names[m.Piece] += msg.String()
+			m.ClearError()
+		}
+	}
+
+	return m, nil
+}
+
+func (m SplitPromptModel) View() string {
+	s := m.RenderTitle()
+	if m.Naming {
+		return s + m.viewNaming()
+	}
+
+	s += fmt.Sprintf("Mark where %s should be split, oldest commit first.\n\n", m.Branch)
+
+	piece := 1
+	for i, commit := range m.Commits {
+		itemStyle := styles.Normal
+		if i == m.Cursor {
+			itemStyle = styles.Selected
+		}
+		s += fmt.Sprintf("%s %s %s\n", styles.CursorStyle(i == m.Cursor), styles.Subdued.Render(fmt.Sprintf("%d │", piece)), itemStyle.Render(commit))
+
+		if m.SplitAfter[i] && i < len(m.Commits)-1 {
+			s += styles.Highlight.Render("    ✂️ ────────") + "\n"
+			piece++
+		}
+	}
+
+	s += m.RenderError()
+	s += m.RenderHelpText("↑/↓: Navigate • Space: Split after commit • Enter: Name branches • Esc: Cancel")
+	return s
+}
+
+func (m SplitPromptModel) viewNaming() string {
+	s := "Name the branch for each piece, from the bottom of the stack up.\n\n"
+
+	ends := m.PieceEnds()
+	start := 0
+	for i, end := range ends {
+		label := fmt.Sprintf("Piece %d (%d commit(s), up to %s): ", i+1, end-start+1, strings.SplitN(m.Commits[end], " ", 2)[0])
+		start = end + 1
+
+		switch {
+		case i == m.Piece:
+			s += styles.Selected.Render(label) + m.Names[i] +
+				styles.Normal.Background(lipgloss.Color(styles.ColorSubdued)).Render(" ")
+		case i < m.Piece:
+			s += label + m.Names[i]
+		default:
+			s += label + styles.Subdued.Render(m.Names[i])
+		}
+		s += "\n"
+	}
+
+	s += m.RenderError()
+	s += m.RenderHelpText("Enter: Confirm name • Esc: Back to commits • Ctrl+C: Cancel")
+	return s
+}
+
+// RunSplitPrompt shows a branch's commits, oldest first, and returns the index of the last
+// commit of each piece with the names picked for them
+func RunSplitPrompt(branch string, commits []string) ([]int, []string, bool) {
+	p := tea.NewProgram(NewSplitPromptModel(branch, commits))
+
+	m, err := p.Run()
+	if err != nil {
+		fmt.Printf("Error running prompt: %v\n", err)
+		return nil, nil, false
+	}
+
+	if m, ok := m.(SplitPromptModel); ok && !m.IsCancelled() && m.Naming && m.Piece == len(m.Names) {
+		return m.PieceEnds(), m.Names, true
+	}
+	return nil, nil, false
+}